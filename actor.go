@@ -0,0 +1,168 @@
+package zstate
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrActorStopped is returned by Actor.Send, and reported for events still in the
+// mailbox, once the context of the actor has been cancelled
+var ErrActorStopped = errors.New("actor stopped")
+
+// defaultMailboxSize is the mailbox size of an Actor unless set with WithMailboxSize
+const defaultMailboxSize = 16
+
+// Result is the outcome of an event processed by an Actor
+type Result[S, E comparable] struct {
+	Event E
+	// From and To are the states of the machine before and after the event
+	From S
+	To   S
+	Err  error
+}
+
+// Actor runs a Machine in its own goroutine, processing the events sent to it one at a time.
+type Actor[S, E comparable] struct {
+	machine *Machine[S, E]
+	mailbox chan envelope[S, E]
+	// mu guards stopped; Send holds it for reading while it puts an event in the mailbox
+	mu      sync.RWMutex
+	stopped bool
+	// stopping is closed when shutdown begins and done once the goroutine has exited
+	stopping chan struct{}
+	done     chan struct{}
+}
+
+// envelope is an event waiting in the mailbox of an Actor
+type envelope[S, E comparable] struct {
+	ctx     context.Context
+	event   E
+	payload any
+	reply   chan Result[S, E]
+}
+
+// ActorOption is a function type for configuring an Actor
+type ActorOption func(*actorConfig)
+
+type actorConfig struct {
+	mailboxSize int
+}
+
+// WithMailboxSize sets how many events can wait in the mailbox of an Actor before Send blocks.
+// The default is 16.
+func WithMailboxSize(size int) ActorOption {
+	return func(c *actorConfig) {
+		c.mailboxSize = size
+	}
+}
+
+// NewActor starts an Actor processing events for m until ctx is cancelled.
+// Once ctx is cancelled, the actor finishes the event it is processing and replies
+// ErrActorStopped to the events left in its mailbox.
+func NewActor[S, E comparable](ctx context.Context, m *Machine[S, E], opts ...ActorOption) *Actor[S, E] {
+	config := actorConfig{mailboxSize: defaultMailboxSize}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	a := &Actor[S, E]{
+		machine:  m,
+		mailbox:  make(chan envelope[S, E], config.mailboxSize),
+		stopping: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go a.run(ctx)
+	return a
+}
+
+// Machine returns the machine run by the actor
+func (a *Actor[S, E]) Machine() *Machine[S, E] {
+	return a.machine
+}
+
+// Done returns a channel that is closed once the actor has stopped and replied to every event in its mailbox
+func (a *Actor[S, E]) Done() <-chan struct{} {
+	return a.done
+}
+
+// Send puts event in the mailbox of the actor and returns a channel that receives its Result.
+// ctx is passed to the guards and callbacks; if it is cancelled before the event is processed,
+// the Result reports ctx.Err() instead. Send blocks while the mailbox is full and returns
+// ctx.Err() if ctx is cancelled in the meantime, or ErrActorStopped if the actor has stopped.
+func (a *Actor[S, E]) Send(ctx context.Context, event E) (<-chan Result[S, E], error) {
+	return a.SendWith(ctx, event, nil)
+}
+
+// SendWith works like Send for an event carrying a payload, as described for TriggerWith
+func (a *Actor[S, E]) SendWith(ctx context.Context, event E, payload any) (<-chan Result[S, E], error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.stopped {
+		return nil, ErrActorStopped
+	}
+
+	reply := make(chan Result[S, E], 1)
+	select {
+	case a.mailbox <- envelope[S, E]{ctx: ctx, event: event, payload: payload, reply: reply}:
+		return reply, nil
+	case <-a.stopping:
+		return nil, ErrActorStopped
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// run processes the mailbox until ctx is cancelled
+func (a *Actor[S, E]) run(ctx context.Context) {
+	defer close(a.done)
+	for {
+		// Stop before taking another event even if the mailbox is not empty
+		if ctx.Err() != nil {
+			a.stop()
+			return
+		}
+		select {
+		case <-ctx.Done():
+			a.stop()
+			return
+		case env := <-a.mailbox:
+			env.reply <- a.process(env)
+		}
+	}
+}
+
+// process fires the event of env on the machine
+func (a *Actor[S, E]) process(env envelope[S, E]) Result[S, E] {
+	m := a.machine
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r := Result[S, E]{Event: env.event, From: m.current}
+	if err := env.ctx.Err(); err != nil {
+		r.Err = err
+	} else {
+		r.Err = m.fire(env.ctx, env.event, &dispatch{payload: env.payload})
+	}
+	r.To = m.current
+	return r
+}
+
+// stop rejects further events and replies ErrActorStopped to the events left in the mailbox
+func (a *Actor[S, E]) stop() {
+	close(a.stopping)
+	// Wait for senders that are putting an event in the mailbox
+	a.mu.Lock()
+	a.stopped = true
+	a.mu.Unlock()
+
+	current := a.machine.Current()
+	for {
+		select {
+		case env := <-a.mailbox:
+			env.reply <- Result[S, E]{Event: env.event, From: current, To: current, Err: ErrActorStopped}
+		default:
+			return
+		}
+	}
+}
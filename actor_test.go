@@ -0,0 +1,181 @@
+package zstate_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/upamune/zstate"
+)
+
+func TestActor(t *testing.T) {
+	t.Parallel()
+
+	t.Run("results", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		a := zstate.NewActor(ctx, newJobMachine(t, nil))
+
+		for _, tt := range []struct {
+			event JobEvent
+			from  JobState
+			to    JobState
+		}{
+			{Start, Queued, Running},
+			{Complete, Running, Completed},
+		} {
+			results, err := a.Send(ctx, tt.event)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			r := <-results
+			if r.Err != nil {
+				t.Fatalf("Unexpected error for %v: %v", tt.event, r.Err)
+			}
+			if r.Event != tt.event || r.From != tt.from || r.To != tt.to {
+				t.Errorf("Expected %v: %v -> %v, got %v: %v -> %v", tt.event, tt.from, tt.to, r.Event, r.From, r.To)
+			}
+		}
+
+		results, err := a.Send(ctx, Start)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var noTransitionErr *zstate.NoTransitionError[JobState, JobEvent]
+		if r := <-results; !errors.As(r.Err, &noTransitionErr) || r.To != Completed {
+			t.Errorf("Expected NoTransitionError in Completed, got %v in %v", r.Err, r.To)
+		}
+	})
+
+	t.Run("concurrent senders", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var holds int
+		a := zstate.NewActor(ctx, newJobMachine(t, func(ctx context.Context, from, to JobState, event JobEvent) {
+			holds++
+		}), zstate.WithMailboxSize(1))
+
+		if r := <-mustSend(t, ctx, a, Start); r.Err != nil {
+			t.Fatalf("Unexpected error: %v", r.Err)
+		}
+
+		var wg sync.WaitGroup
+		for range 50 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for _, event := range []JobEvent{Hold, Start} {
+					results, err := a.Send(ctx, event)
+					if err != nil {
+						t.Errorf("Unexpected error: %v", err)
+						return
+					}
+					<-results
+				}
+			}()
+		}
+		wg.Wait()
+
+		if current := a.Machine().Current(); current != Running && current != Held {
+			t.Errorf("Expected state Running or Held, got %v", current)
+		}
+		if holds == 0 || holds > 50 {
+			t.Errorf("Expected between 1 and 50 holds, got %d", holds)
+		}
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		holding, release := make(chan struct{}), make(chan struct{})
+		a := zstate.NewActor(ctx, newJobMachine(t, func(ctx context.Context, from, to JobState, event JobEvent) {
+			close(holding)
+			<-release
+		}))
+
+		mustSend(t, context.Background(), a, Start)
+		held := mustSend(t, context.Background(), a, Hold)
+		<-holding
+		queued := mustSend(t, context.Background(), a, Start)
+
+		// Hold is being processed while the actor is cancelled
+		cancel()
+		close(release)
+		<-a.Done()
+
+		if r := <-held; r.Err != nil || r.To != Held {
+			t.Errorf("Expected Held, got %v (err: %v)", r.To, r.Err)
+		}
+		if r := <-queued; !errors.Is(r.Err, zstate.ErrActorStopped) {
+			t.Errorf("Expected ErrActorStopped, got %v", r.Err)
+		}
+		if _, err := a.Send(context.Background(), Start); !errors.Is(err, zstate.ErrActorStopped) {
+			t.Errorf("Expected ErrActorStopped, got %v", err)
+		}
+	})
+
+	t.Run("cancelled event", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		release := make(chan struct{})
+		a := zstate.NewActor(ctx, newJobMachine(t, func(ctx context.Context, from, to JobState, event JobEvent) {
+			<-release
+		}))
+
+		mustSend(t, ctx, a, Start)
+		held := mustSend(t, ctx, a, Hold)
+		eventCtx, cancelEvent := context.WithCancel(ctx)
+		cancelled := mustSend(t, eventCtx, a, Start)
+		cancelEvent()
+		close(release)
+
+		<-held
+		if r := <-cancelled; !errors.Is(r.Err, context.Canceled) || r.To != Held {
+			t.Errorf("Expected context.Canceled in Held, got %v in %v", r.Err, r.To)
+		}
+	})
+}
+
+func mustSend(t *testing.T, ctx context.Context, a *zstate.Actor[JobState, JobEvent], event JobEvent) <-chan zstate.Result[JobState, JobEvent] {
+	t.Helper()
+
+	results, err := a.Send(ctx, event)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return results
+}
+
+func newJobMachine(t *testing.T, onHold zstate.TransitionCallback[JobState, JobEvent]) *zstate.Machine[JobState, JobEvent] {
+	t.Helper()
+
+	var opts []zstate.TransitionOption[JobState, JobEvent]
+	if onHold != nil {
+		opts = append(opts, zstate.WithBefore(onHold))
+	}
+
+	builder := zstate.NewStateMachineBuilder[JobState, JobEvent]()
+	sm, err := builder.
+		AddState(Queued).
+		AddState(Running).
+		AddState(Held).
+		AddFinalState(Completed).
+		AddTransition(Queued, Running, Start).
+		AddTransition(Running, Held, Hold, opts...).
+		AddTransition(Held, Running, Start).
+		AddTransition(Running, Completed, Complete).
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build state machine: %v", err)
+	}
+
+	m, err := zstate.NewMachine(sm, Queued)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return m
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/upamune/zstate"
+)
+
+// config holds the settings of a generated file
+type config struct {
+	// typeName names the state machine; the generated types and functions start with it
+	typeName string
+	pkg      string
+	// prefix is prepended to the names of the state and event constants
+	prefix string
+	// source is the name of the definition file, mentioned in the header
+	source string
+}
+
+// readDefinition reads the definition in path, in SCXML if its extension is .scxml and in JSON otherwise
+func readDefinition(path string) (*zstate.Definition[string, string], error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if filepath.Ext(path) == ".scxml" {
+		return zstate.ParseSCXML[string, string](f)
+	}
+	var def zstate.Definition[string, string]
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&def); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &def, nil
+}
+
+// file is the data of the generated file
+type file struct {
+	Source    string
+	Package   string
+	Type      string
+	StateType string
+	EventType string
+	Callbacks string
+	Builder   string
+	States    []constant
+	Events    []constant
+	// Interfaces are the interfaces of the transitions and states using named guards or actions
+	Interfaces []iface
+	// Initial is the constant of the initial state, or empty if there is none
+	Initial string
+	// DefStates and DefTransitions are the elements of the definition, as Go composite literals
+	DefStates      []string
+	DefTransitions []string
+	Guards         []registration
+	Actions        []registration
+	// NeedsPtr reports whether the builder takes the address of states
+	NeedsPtr bool
+}
+
+// constant is a state or event constant
+type constant struct {
+	Name  string
+	Value string
+}
+
+// iface is the interface of a transition or state
+type iface struct {
+	Name    string
+	Doc     string
+	Methods []method
+}
+
+// method is a guard or action of an interface
+type method struct {
+	Name  string
+	Doc   string
+	Guard bool
+}
+
+// registration registers the method of the callbacks implementing a guard or action
+type registration struct {
+	Name   string
+	Method string
+}
+
+// generator collects the data of the generated file and the errors found in the definition
+type generator struct {
+	file
+	// states and events map names to their constant
+	states map[string]string
+	events map[string]string
+	// methods maps guard and action names to their method, and kinds to "guard" or "action"
+	methods map[string]string
+	kinds   map[string]string
+	// idents maps generated identifiers to what they declare, to report clashes
+	idents map[string]string
+	errs   []string
+}
+
+// generate returns the formatted Go source for def
+func generate(def *zstate.Definition[string, string], cfg config) ([]byte, error) {
+	if !token.IsIdentifier(cfg.typeName) || !token.IsExported(cfg.typeName) {
+		return nil, fmt.Errorf("type %q is not an exported Go identifier", cfg.typeName)
+	}
+	if !token.IsIdentifier(cfg.pkg) {
+		return nil, fmt.Errorf("package %q is not a Go identifier", cfg.pkg)
+	}
+
+	g := &generator{
+		file: file{
+			Source:    cfg.source,
+			Package:   cfg.pkg,
+			Type:      cfg.typeName,
+			StateType: cfg.typeName + "State",
+			EventType: cfg.typeName + "Event",
+			Callbacks: cfg.typeName + "Callbacks",
+			Builder:   "New" + cfg.typeName + "Builder",
+		},
+		states:  make(map[string]string),
+		events:  make(map[string]string),
+		methods: make(map[string]string),
+		kinds:   make(map[string]string),
+		idents:  make(map[string]string),
+	}
+	for _, name := range []string{g.StateType, g.EventType, g.Callbacks, g.Builder} {
+		g.declare(name, "the generated "+name)
+	}
+	g.collect(def, cfg.prefix)
+	if len(g.errs) > 0 {
+		return nil, fmt.Errorf("invalid definition:\n\t%s", strings.Join(g.errs, "\n\t"))
+	}
+
+	var buf bytes.Buffer
+	if err := fileTemplate.Execute(&buf, g.file); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return src, nil
+}
+
+// collect fills the file data from def
+func (g *generator) collect(def *zstate.Definition[string, string], prefix string) {
+	for _, s := range def.States {
+		if _, ok := g.states[s.Name]; ok {
+			g.errorf("state %q is declared twice", s.Name)
+			continue
+		}
+		name := g.constant(prefix, s.Name, "state")
+		g.states[s.Name] = name
+		g.States = append(g.States, constant{Name: name, Value: strconv.Quote(s.Name)})
+	}
+	for _, t := range def.Transitions {
+		if _, ok := g.events[t.Event]; !ok {
+			name := g.constant(prefix, t.Event, "event")
+			g.events[t.Event] = name
+			g.Events = append(g.Events, constant{Name: name, Value: strconv.Quote(t.Event)})
+		}
+	}
+
+	if def.Initial != nil {
+		g.Initial = g.state(*def.Initial, "initial state")
+		g.NeedsPtr = true
+	}
+	for _, s := range def.States {
+		g.stateDefinition(s)
+	}
+	for _, t := range def.Transitions {
+		g.transitionDefinition(t)
+	}
+}
+
+// stateDefinition adds the definition and the interface of s
+func (g *generator) stateDefinition(s zstate.StateDefinition[string]) {
+	fields := []string{"Name: " + g.states[s.Name]}
+	if s.Parent != nil {
+		fields = append(fields, "Parent: ptr("+g.state(*s.Parent, fmt.Sprintf("parent of state %q", s.Name))+")")
+		g.NeedsPtr = true
+	}
+	if s.Final {
+		fields = append(fields, "Final: true")
+	}
+	fields = appendNames(fields, "OnEnter", s.OnEnter)
+	fields = appendNames(fields, "OnExit", s.OnExit)
+	g.DefStates = append(g.DefStates, "{"+strings.Join(fields, ", ")+"}")
+
+	var methods []method
+	for _, name := range s.OnEnter {
+		methods = g.addMethod(methods, name, "action", fmt.Sprintf("runs when entering %s", s.Name))
+	}
+	for _, name := range s.OnExit {
+		methods = g.addMethod(methods, name, "action", fmt.Sprintf("runs when leaving %s", s.Name))
+	}
+	if len(methods) > 0 {
+		g.addInterface(iface{
+			Name:    g.Type + goName(s.Name) + "Actions",
+			Doc:     fmt.Sprintf("lists the entry and exit actions of state %s", s.Name),
+			Methods: methods,
+		})
+	}
+}
+
+// transitionDefinition adds the definition and the interface of t
+func (g *generator) transitionDefinition(t zstate.TransitionDefinition[string, string]) {
+	from, source := "Any", "any state"
+	var fields []string
+	if t.Any {
+		fields = append(fields, "Any: true")
+	} else {
+		from, source = goName(t.From), t.From
+		fields = append(fields, "From: "+g.state(t.From, fmt.Sprintf("source of the transition on %q", t.Event)))
+	}
+	fields = append(fields,
+		"To: "+g.state(t.To, fmt.Sprintf("target of the transition on %q", t.Event)),
+		"Event: "+g.events[t.Event])
+	if t.Internal {
+		if t.Any || t.To != t.From {
+			g.errorf("internal transition on %q must target its source state", t.Event)
+		}
+		fields = append(fields, "Internal: true")
+	}
+	if t.Default {
+		fields = append(fields, "Default: true")
+	}
+	fields = appendNames(fields, "Guards", t.Guards)
+	fields = appendNames(fields, "Before", t.Before)
+	fields = appendNames(fields, "After", t.After)
+	g.DefTransitions = append(g.DefTransitions, "{"+strings.Join(fields, ", ")+"}")
+
+	var methods []method
+	for _, name := range t.Guards {
+		methods = g.addMethod(methods, name, "guard", "guards the transition")
+	}
+	for _, name := range t.Before {
+		methods = g.addMethod(methods, name, "action", "runs before the transition")
+	}
+	for _, name := range t.After {
+		methods = g.addMethod(methods, name, "action", "runs after the transition")
+	}
+	if len(methods) > 0 {
+		g.addInterface(iface{
+			Name:    g.Type + from + "To" + goName(t.To) + "On" + goName(t.Event) + "Callbacks",
+			Doc:     fmt.Sprintf("lists the guards and actions of the transition from %s to %s on %s", source, t.To, t.Event),
+			Methods: methods,
+		})
+	}
+}
+
+// constant returns the name of the constant for the state or event value
+func (g *generator) constant(prefix, value, kind string) string {
+	name := prefix + goName(value)
+	if !token.IsIdentifier(name) {
+		g.errorf("%s %q does not make a Go identifier", kind, value)
+		return name
+	}
+	g.declare(name, fmt.Sprintf("%s %q", kind, value))
+	return name
+}
+
+// state returns the constant of the declared state name, used as what
+func (g *generator) state(name, what string) string {
+	c, ok := g.states[name]
+	if !ok {
+		g.errorf("%s %q is not a declared state", what, name)
+	}
+	return c
+}
+
+// addMethod adds the method of the guard or action name to methods, unless it is already there
+func (g *generator) addMethod(methods []method, name, kind, doc string) []method {
+	m := goName(name)
+	if !token.IsIdentifier(m) {
+		g.errorf("%s %q does not make a Go identifier", kind, name)
+		return methods
+	}
+	if prev, ok := g.kinds[name]; ok && prev != kind {
+		g.errorf("%q is used both as a guard and as an action", name)
+		return methods
+	}
+	if _, ok := g.methods[name]; !ok {
+		for other, om := range g.methods {
+			if om == m {
+				g.errorf("%q and %q both make the method %s", other, name, m)
+				return methods
+			}
+		}
+		g.methods[name] = m
+		g.kinds[name] = kind
+		r := registration{Name: strconv.Quote(name), Method: m}
+		if kind == "guard" {
+			g.Guards = append(g.Guards, r)
+		} else {
+			g.Actions = append(g.Actions, r)
+		}
+	}
+
+	for _, existing := range methods {
+		if existing.Name == m {
+			return methods
+		}
+	}
+	return append(methods, method{Name: m, Doc: doc, Guard: kind == "guard"})
+}
+
+// addInterface adds i unless its name is taken
+func (g *generator) addInterface(i iface) {
+	if g.declare(i.Name, "interface "+i.Name) {
+		g.Interfaces = append(g.Interfaces, i)
+	}
+}
+
+// declare records the identifier name for what, reporting whether it was free
+func (g *generator) declare(name, what string) bool {
+	if prev, ok := g.idents[name]; ok {
+		g.errorf("%s and %s both declare %s", prev, what, name)
+		return false
+	}
+	g.idents[name] = what
+	return true
+}
+
+func (g *generator) errorf(format string, args ...any) {
+	g.errs = append(g.errs, fmt.Sprintf(format, args...))
+}
+
+// appendNames appends the field holding names as a string slice literal, if there are names
+func appendNames(fields []string, field string, names []string) []string {
+	if len(names) == 0 {
+		return fields
+	}
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = strconv.Quote(name)
+	}
+	return append(fields, field+": []string{"+strings.Join(quoted, ", ")+"}")
+}
+
+// goName turns name into an exported Go identifier: words separated by anything but
+// letters and digits are joined with their first letter upper-cased
+func goName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			upper = true
+		case upper:
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+var fileTemplate = template.Must(template.New("file").Parse(`// Code generated by zstategen from {{.Source}}; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"context"
+
+	"github.com/upamune/zstate"
+)
+
+// {{.StateType}} is a state of the {{.Type}} state machine
+type {{.StateType}} string
+
+const (
+{{- range .States}}
+	{{.Name}} {{$.StateType}} = {{.Value}}
+{{- end}}
+)
+
+// String returns the name of the state
+func (s {{.StateType}}) String() string {
+	return string(s)
+}
+
+// {{.EventType}} is an event of the {{.Type}} state machine
+type {{.EventType}} string
+
+const (
+{{- range .Events}}
+	{{.Name}} {{$.EventType}} = {{.Value}}
+{{- end}}
+)
+
+// String returns the name of the event
+func (e {{.EventType}}) String() string {
+	return string(e)
+}
+{{range .Interfaces}}
+// {{.Name}} {{.Doc}}
+type {{.Name}} interface {
+{{- range .Methods}}
+	// {{.Name}} {{.Doc}}
+	{{.Name}}(ctx context.Context, from, to {{$.StateType}}, event {{$.EventType}}){{if .Guard}} bool{{end}}
+{{- end}}
+}
+{{end}}
+// {{.Callbacks}} is implemented by the application to provide the guards and actions of the {{.Type}} state machine
+type {{.Callbacks}} interface {
+{{- range .Interfaces}}
+	{{.Name}}
+{{- end}}
+}
+
+// {{.Builder}} returns a builder populated with the states and transitions of the {{.Type}}
+// state machine, using the methods of c as guards and actions. More states and transitions
+// can be added before building it.
+func {{.Builder}}(c {{.Callbacks}}, opts ...zstate.BuilderOption) (zstate.StateMachineBuilder[{{.StateType}}, {{.EventType}}], error) {
+{{- if .NeedsPtr}}
+	ptr := func(s {{.StateType}}) *{{.StateType}} { return &s }
+{{- end}}
+	def := &zstate.Definition[{{.StateType}}, {{.EventType}}]{
+{{- if .Initial}}
+		Initial: ptr({{.Initial}}),
+{{- end}}
+		States: []zstate.StateDefinition[{{.StateType}}]{
+{{- range .DefStates}}
+			{{.}},
+{{- end}}
+		},
+		Transitions: []zstate.TransitionDefinition[{{.StateType}}, {{.EventType}}]{
+{{- range .DefTransitions}}
+			{{.}},
+{{- end}}
+		},
+	}
+	registry := zstate.NewRegistry[{{.StateType}}, {{.EventType}}]()
+{{- range .Guards}}
+	registry.RegisterGuard({{.Name}}, c.{{.Method}})
+{{- end}}
+{{- range .Actions}}
+	registry.RegisterAction({{.Name}}, c.{{.Method}})
+{{- end}}
+	return def.Builder(registry, opts...)
+}
+`))
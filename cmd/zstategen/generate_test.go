@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/upamune/zstate"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		input  string
+		cfg    config
+		golden string
+	}{
+		{"testdata/door.json", config{typeName: "Door", pkg: "door"}, "testdata/door.golden"},
+		{"testdata/player.scxml", config{typeName: "Player", pkg: "player", prefix: "Player"}, "testdata/player.golden"},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			t.Parallel()
+			def, err := readDefinition(tt.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			tt.cfg.source = filepath.Base(tt.input)
+			got, err := generate(def, tt.cfg)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			assertGolden(t, string(got), tt.golden)
+		})
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	t.Parallel()
+
+	states := []zstate.StateDefinition[string]{{Name: "Closed"}, {Name: "Open"}}
+	for _, tt := range []struct {
+		name string
+		def  zstate.Definition[string, string]
+		cfg  config
+		want string
+	}{
+		{
+			name: "unexported type",
+			def:  zstate.Definition[string, string]{States: states},
+			cfg:  config{typeName: "door", pkg: "door"},
+			want: `type "door" is not an exported Go identifier`,
+		},
+		{
+			name: "undeclared state",
+			def: zstate.Definition[string, string]{States: states, Transitions: []zstate.TransitionDefinition[string, string]{
+				{From: "Closed", To: "Locked", Event: "LockDoor"},
+			}},
+			want: `target of the transition on "LockDoor" "Locked" is not a declared state`,
+		},
+		{
+			name: "clashing constants",
+			def: zstate.Definition[string, string]{States: states, Transitions: []zstate.TransitionDefinition[string, string]{
+				{From: "Closed", To: "Open", Event: "Open"},
+			}},
+			want: `state "Open" and event "Open" both declare Open`,
+		},
+		{
+			name: "guard used as action",
+			def: zstate.Definition[string, string]{States: states, Transitions: []zstate.TransitionDefinition[string, string]{
+				{From: "Closed", To: "Open", Event: "OpenDoor", Guards: []string{"check"}, After: []string{"check"}},
+			}},
+			want: `"check" is used both as a guard and as an action`,
+		},
+		{
+			name: "clashing methods",
+			def: zstate.Definition[string, string]{States: states, Transitions: []zstate.TransitionDefinition[string, string]{
+				{From: "Closed", To: "Open", Event: "OpenDoor", Before: []string{"log-open"}, After: []string{"logOpen"}},
+			}},
+			want: `"log-open" and "logOpen" both make the method LogOpen`,
+		},
+		{
+			name: "invalid identifier",
+			def:  zstate.Definition[string, string]{States: []zstate.StateDefinition[string]{{Name: "1st"}}},
+			want: `state "1st" does not make a Go identifier`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := tt.cfg
+			if cfg.typeName == "" {
+				cfg = config{typeName: "Door", pkg: "door"}
+			}
+			_, err := generate(&tt.def, cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	output := filepath.Join(t.TempDir(), "door_zstate.go")
+	if err := run([]string{"-type", "Door", "-package", "door", "-o", output, "testdata/door.json"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assertGolden(t, string(got), "testdata/door.golden")
+
+	if err := run([]string{"-package", "door", "testdata/door.json"}); err == nil {
+		t.Error("Expected error without -type, got nil")
+	}
+}
+
+func assertGolden(t *testing.T, got, goldenFile string) {
+	t.Helper()
+
+	if *update {
+		if err := os.WriteFile(goldenFile, []byte(got), 0644); err != nil {
+			t.Fatalf("Failed to update golden file: %v", err)
+		}
+	}
+
+	expected, err := os.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+
+	if got != string(expected) {
+		t.Errorf("Generated code does not match golden file.\nExpected:\n%s\n\nGot:\n%s", expected, got)
+	}
+}
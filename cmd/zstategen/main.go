@@ -0,0 +1,86 @@
+// Zstategen generates typed Go code for a state machine described by a zstate definition.
+//
+// Given a definition in JSON, as decoded into zstate.Definition, or in SCXML, as read by
+// zstate.ParseSCXML, zstategen writes a Go file declaring:
+//
+//   - the state and event types, with a constant and a String method for each state and event
+//   - an interface per transition and per state, listing the named guards and actions the
+//     transition or state uses as typed methods
+//   - an interface embedding all of them, implemented by the application
+//   - a builder function wiring an implementation of that interface into a state machine builder
+//
+// A guard or action that is missing or has the wrong signature is thus reported by the compiler.
+//
+// Usage:
+//
+//	zstategen -type Door [-package door] [-prefix Door] [-o door_zstate.go] door.json
+//
+// It is typically run with go:generate:
+//
+//	//go:generate go run github.com/upamune/zstate/cmd/zstategen -type Door door.json
+//
+// With -type Door, the file declares the types DoorState and DoorEvent, the interface
+// DoorCallbacks and the function NewDoorBuilder. Constants are named after the states and
+// events, with the optional -prefix prepended. Names of guards and actions become exported
+// method names: "hasKey" and "has-key" both become HasKey.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("zstategen: ")
+	if err := run(os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run parses the command line in args, generates the code and writes it to the output file
+func run(args []string) error {
+	fs := flag.NewFlagSet("zstategen", flag.ContinueOnError)
+	typeName := fs.String("type", "", "name of the state machine, used to name the generated types; required")
+	pkg := fs.String("package", os.Getenv("GOPACKAGE"), "package of the generated file; defaults to $GOPACKAGE set by go generate")
+	prefix := fs.String("prefix", "", "prefix of the state and event constants")
+	output := fs.String("o", "", "output file; defaults to <type>_zstate.go")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: zstategen -type Name [flags] definition.json|definition.scxml\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *typeName == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("a -type and a single definition file are required")
+	}
+	if *pkg == "" {
+		return fmt.Errorf("the package could not be determined; set -package")
+	}
+
+	input := fs.Arg(0)
+	def, err := readDefinition(input)
+	if err != nil {
+		return err
+	}
+	src, err := generate(def, config{
+		typeName: *typeName,
+		pkg:      *pkg,
+		prefix:   *prefix,
+		source:   filepath.Base(input),
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", input, err)
+	}
+
+	if *output == "" {
+		*output = strings.ToLower(*typeName) + "_zstate.go"
+	}
+	return os.WriteFile(*output, src, 0644)
+}
@@ -0,0 +1,290 @@
+package zstate
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Definition is a declarative description of a state machine. It can be decoded from
+// JSON with encoding/json or from YAML with the yamlzstate package, and built with a
+// Registry resolving the names of its guards and actions.
+type Definition[S, E comparable] struct {
+	Initial     *S                           `json:"initial,omitempty" yaml:"initial,omitempty"`
+	States      []StateDefinition[S]         `json:"states" yaml:"states"`
+	Transitions []TransitionDefinition[S, E] `json:"transitions,omitempty" yaml:"transitions,omitempty"`
+}
+
+// StateDefinition describes a state of a Definition
+type StateDefinition[S comparable] struct {
+	Name   S    `json:"name" yaml:"name"`
+	Parent *S   `json:"parent,omitempty" yaml:"parent,omitempty"`
+	Final  bool `json:"final,omitempty" yaml:"final,omitempty"`
+	// OnEnter and OnExit name the entry and exit actions of the state
+	OnEnter []string `json:"onEnter,omitempty" yaml:"onEnter,omitempty"`
+	OnExit  []string `json:"onExit,omitempty" yaml:"onExit,omitempty"`
+}
+
+// TransitionDefinition describes a transition of a Definition.
+// From is ignored for transitions from any state.
+type TransitionDefinition[S, E comparable] struct {
+	From  S `json:"from,omitempty" yaml:"from,omitempty"`
+	To    S `json:"to" yaml:"to"`
+	Event E `json:"event" yaml:"event"`
+	// Any makes the transition valid from every non-final state, as added with AddTransitionFromAny
+	Any bool `json:"any,omitempty" yaml:"any,omitempty"`
+	// Internal makes the transition internal, as added with AddInternalTransition; To must equal From
+	// and the transition cannot be from any state
+	Internal bool `json:"internal,omitempty" yaml:"internal,omitempty"`
+	// Default marks the transition as the default branch, as with AsDefault
+	Default bool `json:"default,omitempty" yaml:"default,omitempty"`
+	// Guards, Before and After name the guards and callbacks of the transition
+	Guards []string `json:"guards,omitempty" yaml:"guards,omitempty"`
+	Before []string `json:"before,omitempty" yaml:"before,omitempty"`
+	After  []string `json:"after,omitempty" yaml:"after,omitempty"`
+}
+
+// Registry maps the names used in a Definition to guards and actions.
+// Actions are used as before and after callbacks and as entry and exit actions.
+type Registry[S, E comparable] struct {
+	guards  map[string]Guard[S, E]
+	actions map[string]TransitionCallback[S, E]
+}
+
+// NewRegistry creates a new, empty Registry
+func NewRegistry[S, E comparable]() *Registry[S, E] {
+	return &Registry[S, E]{
+		guards:  make(map[string]Guard[S, E]),
+		actions: make(map[string]TransitionCallback[S, E]),
+	}
+}
+
+// RegisterGuard registers guard under name, replacing any guard registered under the same name
+func (r *Registry[S, E]) RegisterGuard(name string, guard Guard[S, E]) *Registry[S, E] {
+	r.guards[name] = guard
+	return r
+}
+
+// RegisterAction registers action under name, replacing any action registered under the same name
+func (r *Registry[S, E]) RegisterAction(name string, action TransitionCallback[S, E]) *Registry[S, E] {
+	r.actions[name] = action
+	return r
+}
+
+// Build builds the state machine described by d, resolving the names of its guards and
+// actions against r. Names missing from r are reported as StateError and TransitionError
+// values joined into a single error, as are the errors reported by the builder's Build.
+// The built state machine remembers the names, so its Definition method returns d again.
+func (d *Definition[S, E]) Build(r *Registry[S, E], opts ...BuilderOption) (*StateMachine[S, E], error) {
+	b, err := d.Builder(r, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return b.Build()
+}
+
+// Builder works like Build but returns the builder populated from d, so that
+// more states and transitions can be added in code before building.
+func (d *Definition[S, E]) Builder(r *Registry[S, E], opts ...BuilderOption) (StateMachineBuilder[S, E], error) {
+	var errs []error
+	b := NewStateMachineBuilder[S, E](opts...)
+
+	for _, s := range d.States {
+		var stateOpts []StateOption[S, E]
+		if s.Parent != nil {
+			stateOpts = append(stateOpts, WithParent[S, E](*s.Parent))
+		}
+		for _, name := range s.OnEnter {
+			if action, ok := r.actions[name]; ok {
+				stateOpts = append(stateOpts, namedOnEnter(name, action))
+			} else {
+				errs = append(errs, &StateError[S]{State: s.Name, Msg: fmt.Sprintf("entry action %q is not registered", name)})
+			}
+		}
+		for _, name := range s.OnExit {
+			if action, ok := r.actions[name]; ok {
+				stateOpts = append(stateOpts, namedOnExit(name, action))
+			} else {
+				errs = append(errs, &StateError[S]{State: s.Name, Msg: fmt.Sprintf("exit action %q is not registered", name)})
+			}
+		}
+
+		if s.Final {
+			b.AddFinalState(s.Name, stateOpts...)
+		} else {
+			b.AddState(s.Name, stateOpts...)
+		}
+	}
+	if d.Initial != nil {
+		b.SetInitial(*d.Initial)
+	}
+
+	for _, t := range d.Transitions {
+		transitionOpts, transitionErrs := t.options(r)
+		errs = append(errs, transitionErrs...)
+		switch {
+		case t.Any && t.Internal:
+			errs = append(errs, &TransitionError[S, E]{To: t.To, Event: t.Event, Msg: "internal transition must target its source state"})
+		case t.Any:
+			b.AddTransitionFromAny(t.To, t.Event, transitionOpts...)
+		case t.Internal:
+			if t.To != t.From {
+				errs = append(errs, &TransitionError[S, E]{From: t.From, To: t.To, Event: t.Event, Msg: "internal transition must target its source state"})
+			}
+			b.AddInternalTransition(t.From, t.Event, transitionOpts...)
+		default:
+			b.AddTransition(t.From, t.To, t.Event, transitionOpts...)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// options returns the transition options of t, resolving its names against r
+func (t *TransitionDefinition[S, E]) options(r *Registry[S, E]) ([]TransitionOption[S, E], []error) {
+	var opts []TransitionOption[S, E]
+	var errs []error
+	missing := func(kind, name string) {
+		errs = append(errs, &TransitionError[S, E]{From: t.From, To: t.To, Event: t.Event, Msg: fmt.Sprintf("%s %q is not registered", kind, name)})
+	}
+
+	if t.Default {
+		opts = append(opts, AsDefault[S, E]())
+	}
+	for _, name := range t.Guards {
+		if guard, ok := r.guards[name]; ok {
+			opts = append(opts, namedGuard(name, guard))
+		} else {
+			missing("guard", name)
+		}
+	}
+	for _, name := range t.Before {
+		if action, ok := r.actions[name]; ok {
+			opts = append(opts, namedBefore(name, action))
+		} else {
+			missing("before action", name)
+		}
+	}
+	for _, name := range t.After {
+		if action, ok := r.actions[name]; ok {
+			opts = append(opts, namedAfter(name, action))
+		} else {
+			missing("after action", name)
+		}
+	}
+	return opts, errs
+}
+
+// Definition returns the definition of the state machine, listing states and transitions
+// in the order they were declared. Every guard and action must have been added by
+// Definition.Build so that its name is known; transitions with unnamed guards or callbacks,
+// transitions from a set of states, history transitions, regions and timeouts cannot be
+// expressed in a definition and are reported as StateError and TransitionError values
+// joined into a single error.
+func (sm *StateMachine[S, E]) Definition() (*Definition[S, E], error) {
+	var errs []error
+	d := &Definition[S, E]{Initial: clonePtr(sm.initial)}
+
+	for _, name := range sm.stateOrder {
+		st := sm.states[name]
+		s := StateDefinition[S]{
+			Name:    name,
+			Parent:  clonePtr(st.parent),
+			Final:   sm.IsFinal(name),
+			OnEnter: slices.Clone(st.onEnterNames),
+			OnExit:  slices.Clone(st.onExitNames),
+		}
+		if len(st.onEnterNames) != len(st.onEnter) || len(st.onExitNames) != len(st.onExit) {
+			errs = append(errs, &StateError[S]{State: name, Msg: "state has unnamed entry or exit actions"})
+		}
+		if len(sm.regions[name]) > 0 {
+			errs = append(errs, &StateError[S]{State: name, Msg: "regions cannot be expressed in a definition"})
+		}
+		if len(sm.timeouts[name]) > 0 {
+			errs = append(errs, &StateError[S]{State: name, Msg: "timeouts cannot be expressed in a definition"})
+		}
+		d.States = append(d.States, s)
+	}
+
+	for _, t := range sm.declared {
+		unsupported := func(msg string) {
+			errs = append(errs, &TransitionError[S, E]{From: t.from, To: t.to, Event: t.event, Msg: msg})
+		}
+		switch {
+		case t.source == fromSet:
+			unsupported("transition from a set of states cannot be expressed in a definition")
+		case t.history != NoHistory:
+			unsupported("history transition cannot be expressed in a definition")
+		case len(t.accepts) > 0 || len(t.guardNames) != len(t.guards) || len(t.beforeNames) != len(t.befores) || len(t.afterNames) != len(t.afters):
+			unsupported("transition has unnamed guards or callbacks")
+		}
+		d.Transitions = append(d.Transitions, TransitionDefinition[S, E]{
+			From:     t.from,
+			To:       t.to,
+			Event:    t.event,
+			Any:      t.source == fromAny,
+			Internal: t.internal,
+			Default:  t.isDefault,
+			Guards:   slices.Clone(t.guardNames),
+			Before:   slices.Clone(t.beforeNames),
+			After:    slices.Clone(t.afterNames),
+		})
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// namedGuard works like WithGuard and records the name of the guard
+func namedGuard[S, E comparable](name string, guard Guard[S, E]) TransitionOption[S, E] {
+	return func(t *transition[S, E]) {
+		WithGuard(guard)(t)
+		t.guardNames = append(t.guardNames, name)
+	}
+}
+
+// namedBefore works like WithBefore and records the name of the callback
+func namedBefore[S, E comparable](name string, callback TransitionCallback[S, E]) TransitionOption[S, E] {
+	return func(t *transition[S, E]) {
+		WithBefore(callback)(t)
+		t.beforeNames = append(t.beforeNames, name)
+	}
+}
+
+// namedAfter works like WithAfter and records the name of the callback
+func namedAfter[S, E comparable](name string, callback TransitionCallback[S, E]) TransitionOption[S, E] {
+	return func(t *transition[S, E]) {
+		WithAfter(callback)(t)
+		t.afterNames = append(t.afterNames, name)
+	}
+}
+
+// namedOnEnter works like OnEnter and records the name of the action
+func namedOnEnter[S, E comparable](name string, action TransitionCallback[S, E]) StateOption[S, E] {
+	return func(s *state[S, E]) {
+		OnEnter(action)(s)
+		s.onEnterNames = append(s.onEnterNames, name)
+	}
+}
+
+// namedOnExit works like OnExit and records the name of the action
+func namedOnExit[S, E comparable](name string, action TransitionCallback[S, E]) StateOption[S, E] {
+	return func(s *state[S, E]) {
+		OnExit(action)(s)
+		s.onExitNames = append(s.onExitNames, name)
+	}
+}
+
+// clonePtr returns a pointer to a copy of *p, or nil if p is nil
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
@@ -0,0 +1,137 @@
+package zstate_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/upamune/zstate"
+)
+
+func TestDefinition(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("round trip", func(t *testing.T) {
+		t.Parallel()
+		data, err := os.ReadFile("testdata/definition_door.json")
+		if err != nil {
+			t.Fatalf("Failed to read definition: %v", err)
+		}
+		var def zstate.Definition[DoorState, DoorEvent]
+		if err := json.Unmarshal(data, &def); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		var calls []string
+		hasKey := true
+		registry := zstate.NewRegistry[DoorState, DoorEvent]().
+			RegisterGuard("hasKey", func(ctx context.Context, from, to DoorState, event DoorEvent) bool {
+				return hasKey
+			}).
+			RegisterAction("record", func(ctx context.Context, from, to DoorState, event DoorEvent) {
+				calls = append(calls, string(from)+" -> "+string(to))
+			})
+
+		sm, err := def.Build(registry)
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+		if initial, ok := sm.Initial(); !ok || initial != Closed {
+			t.Errorf("Expected initial state Closed, got %v", initial)
+		}
+
+		current := Closed
+		for _, event := range []DoorEvent{OpenDoor, CloseDoor, LockDoor, LockDoor, UnlockDoor} {
+			if current, err = sm.Trigger(ctx, current, event); err != nil {
+				t.Fatalf("Unexpected error for %v: %v", event, err)
+			}
+		}
+		hasKey = false
+		if _, err := sm.Trigger(ctx, Closed, LockDoor); err == nil {
+			t.Error("Expected guard error, got nil")
+		}
+		want := []string{"Closed -> Open", "Closed -> Open", "Closed -> Locked", "Locked -> Closed"}
+		if !slices.Equal(calls, want) {
+			t.Errorf("Expected calls %v, got %v", want, calls)
+		}
+
+		exported, err := sm.Definition()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		got, err := json.MarshalIndent(exported, "", "  ")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if string(got)+"\n" != string(data) {
+			t.Errorf("Exported definition does not match.\nExpected:\n%s\nGot:\n%s", data, got)
+		}
+	})
+
+	t.Run("unregistered names", func(t *testing.T) {
+		t.Parallel()
+		def := zstate.Definition[DoorState, DoorEvent]{
+			States: []zstate.StateDefinition[DoorState]{
+				{Name: Closed, OnEnter: []string{"chime"}},
+				{Name: Open},
+			},
+			Transitions: []zstate.TransitionDefinition[DoorState, DoorEvent]{
+				{From: Closed, To: Open, Event: OpenDoor, Guards: []string{"hasKey"}, After: []string{"log"}},
+			},
+		}
+
+		_, err := def.Build(zstate.NewRegistry[DoorState, DoorEvent]())
+		if err == nil {
+			t.Fatal("Expected error, got nil")
+		}
+		for _, want := range []string{`entry action "chime" is not registered`, `guard "hasKey" is not registered`, `after action "log" is not registered`} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Expected error to contain %q, got %v", want, err)
+			}
+		}
+	})
+
+	t.Run("internal transition from any state", func(t *testing.T) {
+		t.Parallel()
+		def := zstate.Definition[DoorState, DoorEvent]{
+			States: []zstate.StateDefinition[DoorState]{{Name: Closed}, {Name: Open}},
+			Transitions: []zstate.TransitionDefinition[DoorState, DoorEvent]{
+				{To: Closed, Event: CloseDoor, Any: true, Internal: true},
+			},
+		}
+
+		_, err := def.Build(zstate.NewRegistry[DoorState, DoorEvent]())
+		var transitionErr *zstate.TransitionError[DoorState, DoorEvent]
+		if !errors.As(err, &transitionErr) || transitionErr.Msg != "internal transition must target its source state" {
+			t.Errorf("Expected TransitionError for the internal transition, got %v", err)
+		}
+	})
+
+	t.Run("unnamed guard", func(t *testing.T) {
+		t.Parallel()
+		builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent]()
+		sm, err := builder.
+			AddState(Closed).
+			AddState(Open).
+			AddTransition(Closed, Open, OpenDoor, zstate.WithGuard(func(ctx context.Context, from, to DoorState, event DoorEvent) bool {
+				return true
+			})).
+			AddTransition(Open, Closed, CloseDoor).
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+
+		_, err = sm.Definition()
+		var transitionErr *zstate.TransitionError[DoorState, DoorEvent]
+		if !errors.As(err, &transitionErr) || transitionErr.Event != OpenDoor {
+			t.Errorf("Expected TransitionError for OpenDoor, got %v", err)
+		}
+	})
+}
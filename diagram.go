@@ -2,6 +2,7 @@ package zstate
 
 import (
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 )
@@ -14,106 +15,350 @@ const (
 	DOTFormat
 )
 
+// Diagram is a format-independent description of a state machine.
+// States and transitions are sorted for deterministic output.
+type Diagram struct {
+	States      []string
+	Transitions []DiagramTransition
+	// Initial is the initial state, or empty if none was set
+	Initial string
+	Finals  []string
+	Current string
+	// Parents maps each child state to its parent state
+	Parents map[string]string
+	// Regions maps each parallel state to its orthogonal regions.
+	// The first state of a region is its initial state.
+	Regions map[string][][]string
+}
+
+// DiagramTransition represents a transition in a Diagram
+type DiagramTransition struct {
+	From    string
+	To      string
+	Event   string
+	Default bool
+	// History is "H" or "H*" for transitions to the shallow or deep history of To
+	History string
+	// Any marks transitions valid from any state; From is empty for them
+	Any bool
+	// Sources lists the sorted source states of a transition added for a set of states;
+	// From is empty for them
+	Sources []string
+	// Internal marks transitions that do not exit From
+	Internal bool
+}
+
+// anyNode is the node wildcard transitions start from
+const anyNode = "__any"
+
+// Source returns the node the transition starts from: From itself, the node of its set of
+// source states or the wildcard node
+func (t DiagramTransition) Source() string {
+	if t.Any {
+		return anyNode
+	}
+	if len(t.Sources) > 0 {
+		return "__set_" + strings.Join(t.Sources, "_")
+	}
+	return t.From
+}
+
+// hasAny reports whether the diagram contains transitions from any state
+func (d *Diagram) hasAny() bool {
+	return slices.ContainsFunc(d.Transitions, func(t DiagramTransition) bool { return t.Any })
+}
+
+// sets returns the transitions starting from each distinct set of source states
+func (d *Diagram) sets() []DiagramTransition {
+	var sets []DiagramTransition
+	for _, t := range d.Transitions {
+		if len(t.Sources) > 0 && !slices.ContainsFunc(sets, func(s DiagramTransition) bool { return s.Source() == t.Source() }) {
+			sets = append(sets, t)
+		}
+	}
+	return sets
+}
+
+// setLabel returns the label of the node of a set of source states
+func setLabel(sources []string) string {
+	return "{" + strings.Join(sources, ", ") + "}"
+}
+
+// Target returns the node the transition leads to: To itself or its history pseudo-state
+func (t DiagramTransition) Target() string {
+	return historyNode(t.To, t.History)
+}
+
+// historyNode returns the node name of the history pseudo-state of state
+func historyNode(state, history string) string {
+	switch history {
+	case "H":
+		return state + "_history"
+	case "H*":
+		return state + "_deep_history"
+	default:
+		return state
+	}
+}
+
+// histories returns the history pseudo-states of state that are targeted by transitions
+func (d *Diagram) histories(state string) []string {
+	var histories []string
+	for _, t := range d.Transitions {
+		if t.To == state && t.History != "" && !slices.Contains(histories, t.History) {
+			histories = append(histories, t.History)
+		}
+	}
+	sort.Strings(histories)
+	return histories
+}
+
+// Label returns the edge label of the transition.
+// Default branches are marked with [else].
+func (t DiagramTransition) Label() string {
+	if t.Default {
+		return t.Event + " [else]"
+	}
+	return t.Event
+}
+
+func (d *Diagram) isFinal(state string) bool {
+	for _, f := range d.Finals {
+		if f == state {
+			return true
+		}
+	}
+	return false
+}
+
+// children returns the sorted children of state; an empty state returns the top-level states
+func (d *Diagram) children(state string) []string {
+	var children []string
+	for _, s := range d.States {
+		if d.Parents[s] == state {
+			children = append(children, s)
+		}
+	}
+	return children
+}
+
+// region returns the innermost region containing state as the parallel state and the region index
+func (d *Diagram) region(state string) (string, int, bool) {
+	for {
+		parent, ok := d.Parents[state]
+		if !ok {
+			return "", 0, false
+		}
+		for i, region := range d.Regions[parent] {
+			for _, s := range region {
+				if s == state {
+					return parent, i, true
+				}
+			}
+		}
+		state = parent
+	}
+}
+
+// inRegion reports whether both ends of t lie in the given region.
+// Internal transitions are drawn with their state and never belong to a region.
+func (d *Diagram) inRegion(t DiagramTransition, parent string, index int) bool {
+	if t.Internal {
+		return false
+	}
+	fromParent, fromIndex, fromOK := d.region(t.From)
+	toParent, toIndex, toOK := d.region(t.To)
+	if t.History != "" {
+		// The history pseudo-state lives inside To
+		toParent, toIndex, toOK = d.region(d.leaf(t.To))
+	}
+	return fromOK && toOK && fromParent == parent && toParent == parent && fromIndex == index && toIndex == index
+}
+
+// topLevel reports whether t is drawn outside of every region
+func (d *Diagram) topLevel(t DiagramTransition) bool {
+	if t.Internal {
+		return false
+	}
+	parent, index, ok := d.region(t.From)
+	return !ok || !d.inRegion(t, parent, index)
+}
+
+// regionStates returns the sorted states of a region
+func (d *Diagram) regionStates(region []string) []string {
+	states := slices.Clone(region)
+	sort.Strings(states)
+	return states
+}
+
+// leaf returns the first leaf state nested in state, or state itself if it has no children
+func (d *Diagram) leaf(state string) string {
+	for {
+		children := d.children(state)
+		if len(children) == 0 {
+			return state
+		}
+		state = children[0]
+	}
+}
+
 // DiagramGenerator is an interface for generating diagrams
+//
+// Deprecated: DiagramGenerator only sees flat states and one target per source state and event.
+// Implement DiagramRenderer instead.
 type DiagramGenerator interface {
 	Generate(states map[string]struct{}, transitions map[string]map[string]string, currentState string) string
 }
 
+// DiagramRenderer is an interface for rendering a Diagram
+type DiagramRenderer interface {
+	Render(d *Diagram) string
+}
+
 // MermaidGenerator generates Mermaid diagram
 type MermaidGenerator struct{}
 
+// Generate renders the given states and transitions, which map source states and events to target states.
+//
+// Deprecated: Use Render.
 func (g *MermaidGenerator) Generate(states map[string]struct{}, transitions map[string]map[string]string, currentState string) string {
+	return g.Render(newFlatDiagram(states, transitions, currentState))
+}
+
+// Render renders d as a Mermaid state diagram
+func (g *MermaidGenerator) Render(d *Diagram) string {
 	var sb strings.Builder
 
 	sb.WriteString("stateDiagram-v2\n")
+	sb.WriteString("    classDef current fill:lightblue\n")
 
-	// Sort states for deterministic output
-	sortedStates := make([]string, 0, len(states))
-	for state := range states {
-		sortedStates = append(sortedStates, state)
+	if d.Initial != "" {
+		sb.WriteString(fmt.Sprintf("    [*] --> %v\n", d.Initial))
 	}
-	sort.Strings(sortedStates)
 
-	for _, state := range sortedStates {
-		if state == currentState {
-			sb.WriteString(fmt.Sprintf("    %v : [*] %v\n", state, state))
-		} else {
-			sb.WriteString(fmt.Sprintf("    %v\n", state))
-		}
+	for _, state := range d.children("") {
+		g.writeState(&sb, d, state, "    ")
 	}
 
-	// Sort transitions for deterministic output
-	type transition struct {
-		from, to, event string
+	if d.hasAny() {
+		sb.WriteString(fmt.Sprintf("    state \"*\" as %v\n", anyNode))
 	}
-	sortedTransitions := make([]transition, 0)
-	for from, events := range transitions {
-		for event, to := range events {
-			sortedTransitions = append(sortedTransitions, transition{from, to, event})
-		}
+	for _, t := range d.sets() {
+		sb.WriteString(fmt.Sprintf("    state \"%v\" as %v\n", setLabel(t.Sources), t.Source()))
 	}
-	sort.Slice(sortedTransitions, func(i, j int) bool {
-		if sortedTransitions[i].from != sortedTransitions[j].from {
-			return sortedTransitions[i].from < sortedTransitions[j].from
-		}
-		if sortedTransitions[i].to != sortedTransitions[j].to {
-			return sortedTransitions[i].to < sortedTransitions[j].to
+
+	for _, t := range d.Transitions {
+		if d.topLevel(t) {
+			sb.WriteString(fmt.Sprintf("    %v --> %v : %v\n", t.Source(), t.Target(), t.Label()))
 		}
-		return sortedTransitions[i].event < sortedTransitions[j].event
-	})
+	}
+
+	for _, state := range d.Finals {
+		sb.WriteString(fmt.Sprintf("    %v --> [*]\n", state))
+	}
 
-	for _, t := range sortedTransitions {
-		sb.WriteString(fmt.Sprintf("    %v --> %v : %v\n", t.from, t.to, t.event))
+	if d.Current != "" {
+		sb.WriteString(fmt.Sprintf("    class %v current\n", d.Current))
 	}
 
 	return sb.String()
 }
 
+// writeState writes a state, rendering states with children as composite states
+func (g *MermaidGenerator) writeState(sb *strings.Builder, d *Diagram, state, indent string) {
+	children := d.children(state)
+	if len(children) == 0 {
+		sb.WriteString(fmt.Sprintf("%v%v\n", indent, state))
+		g.writeInternal(sb, d, state, indent)
+		return
+	}
+
+	sb.WriteString(fmt.Sprintf("%vstate %v {\n", indent, state))
+	for _, h := range d.histories(state) {
+		sb.WriteString(fmt.Sprintf("%v    state \"%v\" as %v\n", indent, h, historyNode(state, h)))
+	}
+	if regions := d.Regions[state]; len(regions) > 0 {
+		// Regions are separated by Mermaid's concurrency separator and
+		// contain their own initial marker and transitions
+		for i, region := range regions {
+			if i > 0 {
+				sb.WriteString(fmt.Sprintf("%v    --\n", indent))
+			}
+			sb.WriteString(fmt.Sprintf("%v    [*] --> %v\n", indent, region[0]))
+			for _, s := range d.regionStates(region) {
+				g.writeState(sb, d, s, indent+"    ")
+			}
+			for _, t := range d.Transitions {
+				if d.inRegion(t, state, i) {
+					sb.WriteString(fmt.Sprintf("%v    %v --> %v : %v\n", indent, t.From, t.Target(), t.Label()))
+				}
+			}
+		}
+	} else {
+		for _, child := range children {
+			g.writeState(sb, d, child, indent+"    ")
+		}
+	}
+	sb.WriteString(fmt.Sprintf("%v}\n", indent))
+	g.writeInternal(sb, d, state, indent)
+}
+
+// writeInternal writes the internal transitions of a state as its descriptions
+func (g *MermaidGenerator) writeInternal(sb *strings.Builder, d *Diagram, state, indent string) {
+	for _, t := range d.Transitions {
+		if t.Internal && t.From == state {
+			sb.WriteString(fmt.Sprintf("%v%v : %v\n", indent, state, t.Label()))
+		}
+	}
+}
+
 // DOTGenerator generates DOT diagram
 type DOTGenerator struct{}
 
+// Generate renders the given states and transitions, which map source states and events to target states.
+//
+// Deprecated: Use Render.
 func (g *DOTGenerator) Generate(states map[string]struct{}, transitions map[string]map[string]string, currentState string) string {
+	return g.Render(newFlatDiagram(states, transitions, currentState))
+}
+
+// Render renders d as a DOT graph
+func (g *DOTGenerator) Render(d *Diagram) string {
 	var sb strings.Builder
 
 	sb.WriteString("digraph StateMachine {\n")
 
-	// Sort states for deterministic output
-	sortedStates := make([]string, 0, len(states))
-	for state := range states {
-		sortedStates = append(sortedStates, state)
+	if len(d.Parents) > 0 {
+		// compound allows edges to start and end at clusters
+		sb.WriteString("    compound=true;\n")
 	}
-	sort.Strings(sortedStates)
 
-	for _, state := range sortedStates {
-		if state == currentState {
-			sb.WriteString(fmt.Sprintf("    \"%v\" [shape=doublecircle, style=filled, fillcolor=lightblue];\n", state))
-		} else {
-			sb.WriteString(fmt.Sprintf("    \"%v\" [shape=circle];\n", state))
-		}
+	if d.Initial != "" {
+		sb.WriteString("    \"__initial\" [shape=point];\n")
 	}
 
-	// Sort transitions for deterministic output
-	type transition struct {
-		from, to, event string
+	for _, state := range d.children("") {
+		g.writeState(&sb, d, state, "    ")
 	}
-	sortedTransitions := make([]transition, 0)
-	for from, events := range transitions {
-		for event, to := range events {
-			sortedTransitions = append(sortedTransitions, transition{from, to, event})
-		}
+
+	if d.hasAny() {
+		sb.WriteString(fmt.Sprintf("    \"%v\" [shape=plaintext, label=\"*\"];\n", anyNode))
+	}
+	for _, t := range d.sets() {
+		sb.WriteString(fmt.Sprintf("    \"%v\" [shape=plaintext, label=\"%v\"];\n", t.Source(), setLabel(t.Sources)))
+	}
+
+	if d.Initial != "" {
+		sb.WriteString(fmt.Sprintf("    \"__initial\" -> \"%v\"%v;\n", d.leaf(d.Initial), g.edgeAttrs(d, "", d.Initial, "")))
 	}
-	sort.Slice(sortedTransitions, func(i, j int) bool {
-		if sortedTransitions[i].from != sortedTransitions[j].from {
-			return sortedTransitions[i].from < sortedTransitions[j].from
-		}
-		if sortedTransitions[i].to != sortedTransitions[j].to {
-			return sortedTransitions[i].to < sortedTransitions[j].to
-		}
-		return sortedTransitions[i].event < sortedTransitions[j].event
-	})
 
-	for _, t := range sortedTransitions {
-		sb.WriteString(fmt.Sprintf("    \"%v\" -> \"%v\" [label=\"%v\"];\n", t.from, t.to, t.event))
+	for _, t := range d.Transitions {
+		if t.Internal {
+			// Internal transitions are dashed to tell them apart from self-transitions
+			sb.WriteString(fmt.Sprintf("    \"%v\" -> \"%v\" [label=\"%v\", style=dashed];\n", d.leaf(t.From), d.leaf(t.From), t.Label()))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("    \"%v\" -> \"%v\"%v;\n", d.leaf(t.Source()), d.leaf(t.Target()), g.edgeAttrs(d, t.Source(), t.Target(), t.Label())))
 	}
 
 	sb.WriteString("}")
@@ -121,36 +366,225 @@ func (g *DOTGenerator) Generate(states map[string]struct{}, transitions map[stri
 	return sb.String()
 }
 
+// writeState writes a state, rendering states with children as clusters
+func (g *DOTGenerator) writeState(sb *strings.Builder, d *Diagram, state, indent string) {
+	children := d.children(state)
+	if len(children) == 0 {
+		shape := "circle"
+		if d.isFinal(state) {
+			shape = "doublecircle"
+		}
+		if state == d.Current {
+			sb.WriteString(fmt.Sprintf("%v\"%v\" [shape=%v, style=filled, fillcolor=lightblue];\n", indent, state, shape))
+		} else {
+			sb.WriteString(fmt.Sprintf("%v\"%v\" [shape=%v];\n", indent, state, shape))
+		}
+		return
+	}
+
+	sb.WriteString(fmt.Sprintf("%vsubgraph \"cluster_%v\" {\n", indent, state))
+	sb.WriteString(fmt.Sprintf("%v    label=\"%v\";\n", indent, state))
+	if state == d.Current {
+		sb.WriteString(fmt.Sprintf("%v    style=filled;\n%v    fillcolor=lightblue;\n", indent, indent))
+	}
+	for _, h := range d.histories(state) {
+		sb.WriteString(fmt.Sprintf("%v    \"%v\" [shape=circle, label=\"%v\"];\n", indent, historyNode(state, h), h))
+	}
+	if regions := d.Regions[state]; len(regions) > 0 {
+		// Each region is drawn as a dashed subgraph with its own initial point
+		for i, region := range regions {
+			inner := indent + "        "
+			sb.WriteString(fmt.Sprintf("%v    subgraph \"cluster_%v_%d\" {\n", indent, state, i))
+			sb.WriteString(fmt.Sprintf("%vlabel=\"\";\n%vstyle=dashed;\n", inner, inner))
+			sb.WriteString(fmt.Sprintf("%v\"__initial_%v_%d\" [shape=point];\n", inner, state, i))
+			for _, s := range d.regionStates(region) {
+				g.writeState(sb, d, s, inner)
+			}
+			sb.WriteString(fmt.Sprintf("%v\"__initial_%v_%d\" -> \"%v\"%v;\n", inner, state, i, d.leaf(region[0]), g.edgeAttrs(d, "", region[0], "")))
+			sb.WriteString(fmt.Sprintf("%v    }\n", indent))
+		}
+	} else {
+		for _, child := range children {
+			g.writeState(sb, d, child, indent+"    ")
+		}
+	}
+	sb.WriteString(fmt.Sprintf("%v}\n", indent))
+}
+
+// edgeAttrs returns the attribute list of an edge. Edges from or to composite states
+// are drawn between leaf nodes and clipped at the cluster boundary.
+func (g *DOTGenerator) edgeAttrs(d *Diagram, from, to, label string) string {
+	var attrs []string
+	if label != "" {
+		attrs = append(attrs, fmt.Sprintf("label=\"%v\"", label))
+	}
+	if from != "" && d.leaf(from) != from {
+		attrs = append(attrs, fmt.Sprintf("ltail=\"cluster_%v\"", from))
+	}
+	if d.leaf(to) != to {
+		attrs = append(attrs, fmt.Sprintf("lhead=\"cluster_%v\"", to))
+	}
+	if len(attrs) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(attrs, ", ") + "]"
+}
+
 // GenerateDiagram generates a diagram representation of the state machine in the specified format
 func GenerateDiagram[S, E comparable](sm *StateMachine[S, E], format DiagramFormat, currentState S) (string, error) {
-	var generator DiagramGenerator
+	var renderer DiagramRenderer
 
 	switch format {
 	case MermaidFormat:
-		generator = &MermaidGenerator{}
+		renderer = &MermaidGenerator{}
 	case DOTFormat:
-		generator = &DOTGenerator{}
+		renderer = &DOTGenerator{}
 	default:
 		return "", fmt.Errorf("unsupported diagram format")
 	}
 
-	// Convert states and transitions to string maps
-	stringStates := make(map[string]struct{})
+	return renderer.Render(newDiagram(sm, currentState)), nil
+}
+
+// newFlatDiagram converts the arguments of the deprecated DiagramGenerator into a Diagram
+func newFlatDiagram(states map[string]struct{}, transitions map[string]map[string]string, currentState string) *Diagram {
+	d := &Diagram{Current: currentState}
+	for state := range states {
+		d.States = append(d.States, state)
+	}
+	sort.Strings(d.States)
+	for from, events := range transitions {
+		for event, to := range events {
+			d.Transitions = append(d.Transitions, DiagramTransition{From: from, To: to, Event: event})
+		}
+	}
+	sortTransitions(d.Transitions)
+	return d
+}
+
+// newDiagram converts the state machine into a Diagram with sorted states and transitions
+func newDiagram[S, E comparable](sm *StateMachine[S, E], currentState S) *Diagram {
+	d := &Diagram{
+		Current: fmt.Sprintf("%v", currentState),
+	}
+
+	for state := range sm.states {
+		d.States = append(d.States, fmt.Sprintf("%v", state))
+	}
+	sort.Strings(d.States)
+
+	for state := range sm.finals {
+		d.Finals = append(d.Finals, fmt.Sprintf("%v", state))
+	}
+	sort.Strings(d.Finals)
+
+	for parent, regions := range sm.regions {
+		if d.Regions == nil {
+			d.Regions = make(map[string][][]string)
+		}
+		for _, region := range regions {
+			states := make([]string, 0, len(region))
+			for _, s := range region {
+				states = append(states, fmt.Sprintf("%v", s))
+			}
+			d.Regions[fmt.Sprintf("%v", parent)] = append(d.Regions[fmt.Sprintf("%v", parent)], states)
+		}
+	}
+
 	for state := range sm.states {
-		stringStates[fmt.Sprintf("%v", state)] = struct{}{}
+		if parent, ok := sm.Parent(state); ok {
+			if d.Parents == nil {
+				d.Parents = make(map[string]string)
+			}
+			d.Parents[fmt.Sprintf("%v", state)] = fmt.Sprintf("%v", parent)
+		}
+	}
+
+	if initial, ok := sm.Initial(); ok {
+		d.Initial = fmt.Sprintf("%v", initial)
+	}
+
+	for _, events := range sm.transitions {
+		for _, candidates := range events {
+			for _, t := range candidates {
+				d.Transitions = append(d.Transitions, newDiagramTransition(t))
+			}
+		}
+	}
+	// A transition added for a set of states is drawn once, from the first state of its set
+	for _, events := range sm.setTransitions {
+		for _, candidates := range events {
+			for _, t := range candidates {
+				if t.from == t.set[0] {
+					d.Transitions = append(d.Transitions, newDiagramTransition(t))
+				}
+			}
+		}
+	}
+	for _, candidates := range sm.anyTransitions {
+		for _, t := range candidates {
+			d.Transitions = append(d.Transitions, newDiagramTransition(t))
+		}
 	}
+	sortTransitions(d.Transitions)
+
+	return d
+}
+
+// sortTransitions sorts transitions for deterministic output
+func sortTransitions(transitions []DiagramTransition) {
+	sort.Slice(transitions, func(i, j int) bool {
+		if transitions[i].From != transitions[j].From {
+			return transitions[i].From < transitions[j].From
+		}
+		if transitions[i].To != transitions[j].To {
+			return transitions[i].To < transitions[j].To
+		}
+		if transitions[i].Event != transitions[j].Event {
+			return transitions[i].Event < transitions[j].Event
+		}
+		if transitions[i].History != transitions[j].History {
+			return transitions[i].History < transitions[j].History
+		}
+		if transitions[i].Source() != transitions[j].Source() {
+			return transitions[i].Source() < transitions[j].Source()
+		}
+		return !transitions[i].Internal && transitions[j].Internal
+	})
+}
 
-	stringTransitions := make(map[string]map[string]string)
-	for from, events := range sm.transitions {
-		fromStr := fmt.Sprintf("%v", from)
-		stringTransitions[fromStr] = make(map[string]string)
-		for event, transition := range events {
-			eventStr := fmt.Sprintf("%v", event)
-			toStr := fmt.Sprintf("%v", transition.to)
-			stringTransitions[fromStr][eventStr] = toStr
+// newDiagramTransition converts a transition into a DiagramTransition
+func newDiagramTransition[S, E comparable](t transition[S, E]) DiagramTransition {
+	dt := DiagramTransition{
+		From:     fmt.Sprintf("%v", t.from),
+		To:       fmt.Sprintf("%v", t.to),
+		Event:    fmt.Sprintf("%v", t.event),
+		Default:  t.isDefault,
+		History:  historyLabel(t.history),
+		Internal: t.internal,
+	}
+	switch t.source {
+	case fromSet:
+		dt.From = ""
+		for _, s := range t.set {
+			dt.Sources = append(dt.Sources, fmt.Sprintf("%v", s))
 		}
+		sort.Strings(dt.Sources)
+	case fromAny:
+		dt.From = ""
+		dt.Any = true
 	}
+	return dt
+}
 
-	currentStateStr := fmt.Sprintf("%v", currentState)
-	return generator.Generate(stringStates, stringTransitions, currentStateStr), nil
+// historyLabel returns the diagram label of a history kind
+func historyLabel(kind HistoryKind) string {
+	switch kind {
+	case ShallowHistory:
+		return "H"
+	case DeepHistory:
+		return "H*"
+	default:
+		return ""
+	}
 }
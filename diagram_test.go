@@ -15,49 +15,123 @@ func TestGenerateDiagram(t *testing.T) {
 	t.Parallel()
 
 	sm := buildDoorStateMachine(t)
+	lifecycle := buildDoorLifecycleStateMachine(t)
+	internal := buildDoorInternalStateMachine(t)
 
 	tests := []struct {
 		name         string
+		sm           *zstate.StateMachine[DoorState, DoorEvent]
 		format       zstate.DiagramFormat
 		currentState DoorState
 		goldenFile   string
 	}{
 		{
+			sm:           sm,
 			name:         "Mermaid Diagram - Closed State",
 			format:       zstate.MermaidFormat,
 			currentState: Closed,
 			goldenFile:   "testdata/mermaid_closed.golden",
 		},
 		{
+			sm:           sm,
 			name:         "Mermaid Diagram - Open State",
 			format:       zstate.MermaidFormat,
 			currentState: Open,
 			goldenFile:   "testdata/mermaid_open.golden",
 		},
 		{
+			sm:           sm,
 			name:         "Mermaid Diagram - Locked State",
 			format:       zstate.MermaidFormat,
 			currentState: Locked,
 			goldenFile:   "testdata/mermaid_locked.golden",
 		},
 		{
+			sm:           sm,
 			name:         "DOT Diagram - Closed State",
 			format:       zstate.DOTFormat,
 			currentState: Closed,
 			goldenFile:   "testdata/dot_closed.golden",
 		},
 		{
+			sm:           sm,
 			name:         "DOT Diagram - Open State",
 			format:       zstate.DOTFormat,
 			currentState: Open,
 			goldenFile:   "testdata/dot_open.golden",
 		},
 		{
+			sm:           sm,
 			name:         "DOT Diagram - Locked State",
 			format:       zstate.DOTFormat,
 			currentState: Locked,
 			goldenFile:   "testdata/dot_locked.golden",
 		},
+		{
+			name:         "Mermaid Diagram - Initial and Final States",
+			sm:           lifecycle,
+			format:       zstate.MermaidFormat,
+			currentState: Open,
+			goldenFile:   "testdata/mermaid_lifecycle.golden",
+		},
+		{
+			name:         "DOT Diagram - Initial and Final States",
+			sm:           lifecycle,
+			format:       zstate.DOTFormat,
+			currentState: Open,
+			goldenFile:   "testdata/dot_lifecycle.golden",
+		},
+		{
+			name:         "Mermaid Diagram - Internal Transitions",
+			sm:           internal,
+			format:       zstate.MermaidFormat,
+			currentState: Closed,
+			goldenFile:   "testdata/mermaid_internal.golden",
+		},
+		{
+			name:         "DOT Diagram - Internal Transitions",
+			sm:           internal,
+			format:       zstate.DOTFormat,
+			currentState: Closed,
+			goldenFile:   "testdata/dot_internal.golden",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagram, err := zstate.GenerateDiagram(tt.sm, tt.format, tt.currentState)
+			if err != nil {
+				t.Fatalf("Failed to generate diagram: %v", err)
+			}
+
+			assertGolden(t, diagram, tt.goldenFile)
+		})
+	}
+}
+
+func TestGenerateDiagramHierarchy(t *testing.T) {
+	t.Parallel()
+
+	sm, _ := buildOrderStateMachine(t)
+
+	tests := []struct {
+		name         string
+		format       zstate.DiagramFormat
+		currentState OrderState
+		goldenFile   string
+	}{
+		{
+			name:         "Mermaid Diagram - Composite States",
+			format:       zstate.MermaidFormat,
+			currentState: Paid,
+			goldenFile:   "testdata/mermaid_hierarchy.golden",
+		},
+		{
+			name:         "DOT Diagram - Composite States",
+			format:       zstate.DOTFormat,
+			currentState: Paid,
+			goldenFile:   "testdata/dot_hierarchy.golden",
+		},
 	}
 
 	for _, tt := range tests {
@@ -67,29 +141,137 @@ func TestGenerateDiagram(t *testing.T) {
 				t.Fatalf("Failed to generate diagram: %v", err)
 			}
 
-			if *update {
-				err = os.MkdirAll(filepath.Dir(tt.goldenFile), 0755)
-				if err != nil {
-					t.Fatalf("Failed to create golden file directory: %v", err)
-				}
-				err = os.WriteFile(tt.goldenFile, []byte(diagram), 0644)
-				if err != nil {
-					t.Fatalf("Failed to update golden file: %v", err)
-				}
+			assertGolden(t, diagram, tt.goldenFile)
+		})
+	}
+}
+
+func TestGenerateDiagramParallel(t *testing.T) {
+	t.Parallel()
+
+	sm := buildDeviceStateMachine(t)
+
+	tests := []struct {
+		name       string
+		format     zstate.DiagramFormat
+		goldenFile string
+	}{
+		{
+			name:       "Mermaid Diagram - Parallel Regions",
+			format:     zstate.MermaidFormat,
+			goldenFile: "testdata/mermaid_parallel.golden",
+		},
+		{
+			name:       "DOT Diagram - Parallel Regions",
+			format:     zstate.DOTFormat,
+			goldenFile: "testdata/dot_parallel.golden",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagram, err := zstate.GenerateDiagram(sm, tt.format, On)
+			if err != nil {
+				t.Fatalf("Failed to generate diagram: %v", err)
 			}
 
-			expected, err := os.ReadFile(tt.goldenFile)
+			assertGolden(t, diagram, tt.goldenFile)
+		})
+	}
+}
+
+func TestGenerateDiagramHistory(t *testing.T) {
+	t.Parallel()
+
+	sm := buildTaskStateMachine(t)
+
+	tests := []struct {
+		name       string
+		format     zstate.DiagramFormat
+		goldenFile string
+	}{
+		{
+			name:       "Mermaid Diagram - History",
+			format:     zstate.MermaidFormat,
+			goldenFile: "testdata/mermaid_history.golden",
+		},
+		{
+			name:       "DOT Diagram - History",
+			format:     zstate.DOTFormat,
+			goldenFile: "testdata/dot_history.golden",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagram, err := zstate.GenerateDiagram(sm, tt.format, Suspended)
 			if err != nil {
-				t.Fatalf("Failed to read golden file: %v", err)
+				t.Fatalf("Failed to generate diagram: %v", err)
 			}
 
-			if diagram != string(expected) {
-				t.Errorf("Generated diagram does not match golden file.\nExpected:\n%s\n\nGot:\n%s", expected, diagram)
+			assertGolden(t, diagram, tt.goldenFile)
+		})
+	}
+}
+
+func TestGenerateDiagramWildcard(t *testing.T) {
+	t.Parallel()
+
+	sm := buildJobStateMachine(t)
+
+	tests := []struct {
+		name       string
+		format     zstate.DiagramFormat
+		goldenFile string
+	}{
+		{
+			name:       "Mermaid Diagram - Wildcard",
+			format:     zstate.MermaidFormat,
+			goldenFile: "testdata/mermaid_wildcard.golden",
+		},
+		{
+			name:       "DOT Diagram - Wildcard",
+			format:     zstate.DOTFormat,
+			goldenFile: "testdata/dot_wildcard.golden",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagram, err := zstate.GenerateDiagram(sm, tt.format, Running)
+			if err != nil {
+				t.Fatalf("Failed to generate diagram: %v", err)
 			}
+
+			assertGolden(t, diagram, tt.goldenFile)
 		})
 	}
 }
 
+func assertGolden(t *testing.T, diagram, goldenFile string) {
+	t.Helper()
+
+	if *update {
+		err := os.MkdirAll(filepath.Dir(goldenFile), 0755)
+		if err != nil {
+			t.Fatalf("Failed to create golden file directory: %v", err)
+		}
+		err = os.WriteFile(goldenFile, []byte(diagram), 0644)
+		if err != nil {
+			t.Fatalf("Failed to update golden file: %v", err)
+		}
+	}
+
+	expected, err := os.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+
+	if diagram != string(expected) {
+		t.Errorf("Generated diagram does not match golden file.\nExpected:\n%s\n\nGot:\n%s", expected, diagram)
+	}
+}
+
 func TestGenerateDiagramErrors(t *testing.T) {
 	t.Parallel()
 
@@ -104,3 +286,30 @@ func TestGenerateDiagramErrors(t *testing.T) {
 		t.Errorf("Expected error message '%s', got '%s'", expectedErrMsg, err.Error())
 	}
 }
+
+func TestDeprecatedGenerate(t *testing.T) {
+	t.Parallel()
+
+	states := map[string]struct{}{"Closed": {}, "Open": {}, "Locked": {}}
+	transitions := map[string]map[string]string{
+		"Closed": {"OpenDoor": "Open", "LockDoor": "Locked"},
+		"Open":   {"CloseDoor": "Closed"},
+		"Locked": {"UnlockDoor": "Closed"},
+	}
+
+	for _, tt := range []struct {
+		generator  zstate.DiagramGenerator
+		goldenFile string
+	}{
+		{&zstate.MermaidGenerator{}, "testdata/mermaid_closed.golden"},
+		{&zstate.DOTGenerator{}, "testdata/dot_closed.golden"},
+	} {
+		expected, err := os.ReadFile(tt.goldenFile)
+		if err != nil {
+			t.Fatalf("Failed to read golden file: %v", err)
+		}
+		if got := tt.generator.Generate(states, transitions, "Closed"); got != string(expected) {
+			t.Errorf("Generated diagram does not match golden file.\nExpected:\n%s\n\nGot:\n%s", expected, got)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package zstate
 
 import (
 	"fmt"
+	"strings"
 )
 
 // StateError represents an error related to state operations
@@ -20,23 +21,84 @@ type TransitionError[S, E comparable] struct {
 	To    S
 	Event E
 	Msg   string
+	Err   error
 }
 
 func (e *TransitionError[S, E]) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("transition error: %s: %v (from: %v, to: %v, event: %v)", e.Msg, e.Err, e.From, e.To, e.Event)
+	}
 	return fmt.Sprintf("transition error: %s (from: %v, to: %v, event: %v)", e.Msg, e.From, e.To, e.Event)
 }
 
-// GuardError represents an error when a guard condition is not met
+// Unwrap returns the underlying error, if any
+func (e *TransitionError[S, E]) Unwrap() error {
+	return e.Err
+}
+
+// GuardError represents an error when a guard condition is not met.
+// Index identifies the failing guard by its position in the order the guards were added,
+// or is -1 if the transition was rejected because its payload or data has the wrong type.
+// Err holds the error returned by a GuardFunc and is nil when a boolean guard returned false.
+//
+// When several candidate transitions exist for the same source state and event and all
+// of them are rejected, the fields describe the first rejected candidate and Candidates
+// lists every rejected candidate in evaluation order.
 type GuardError[S, E comparable] struct {
-	From  S
-	To    S
-	Event E
+	From       S
+	To         S
+	Event      E
+	Index      int
+	Err        error
+	Candidates []*GuardError[S, E]
+}
+
+// newGuardError combines the rejections of every candidate transition into a single GuardError
+func newGuardError[S, E comparable](rejected []*GuardError[S, E]) *GuardError[S, E] {
+	if len(rejected) == 1 {
+		return rejected[0]
+	}
+	e := *rejected[0]
+	e.Candidates = rejected
+	return &e
 }
 
 func (e *GuardError[S, E]) Error() string {
+	if len(e.Candidates) > 1 {
+		reasons := make([]string, 0, len(e.Candidates))
+		for _, c := range e.Candidates {
+			reason := "condition not met"
+			if c.Err != nil {
+				reason = c.Err.Error()
+			}
+			reasons = append(reasons, fmt.Sprintf("to %v: %s", c.To, reason))
+		}
+		return fmt.Sprintf("guard error: all %d candidate transitions rejected (from: %v, event: %v): %s", len(e.Candidates), e.From, e.Event, strings.Join(reasons, "; "))
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("guard error: %v (from: %v, to: %v, event: %v)", e.Err, e.From, e.To, e.Event)
+	}
 	return fmt.Sprintf("guard error: condition not met (from: %v, to: %v, event: %v)", e.From, e.To, e.Event)
 }
 
+// Unwrap returns the errors returned by the guards, if any.
+// When Candidates is set, it returns the error of every rejected candidate.
+func (e *GuardError[S, E]) Unwrap() []error {
+	if len(e.Candidates) == 0 {
+		if e.Err == nil {
+			return nil
+		}
+		return []error{e.Err}
+	}
+	var errs []error
+	for _, c := range e.Candidates {
+		if c.Err != nil {
+			errs = append(errs, c.Err)
+		}
+	}
+	return errs
+}
+
 // NoTransitionError represents an error when no transition is found
 type NoTransitionError[S, E comparable] struct {
 	From  S
@@ -46,3 +108,55 @@ type NoTransitionError[S, E comparable] struct {
 func (e *NoTransitionError[S, E]) Error() string {
 	return fmt.Sprintf("no transition error: no transition found (from: %v, event: %v)", e.From, e.Event)
 }
+
+// PayloadError represents an error when the payload of an event does not have the type
+// expected by the payload-aware guards and callbacks of a transition
+type PayloadError struct {
+	Payload any
+	Want    string
+}
+
+func (e *PayloadError) Error() string {
+	return fmt.Sprintf("payload error: got %T, want %s", e.Payload, e.Want)
+}
+
+// DataError represents an error when the extended state of an ExtendedStateMachine does not
+// have the type expected by the data guards and actions of a transition
+type DataError struct {
+	Data any
+	Want string
+}
+
+func (e *DataError) Error() string {
+	return fmt.Sprintf("data error: got %T, want %s", e.Data, e.Want)
+}
+
+// CascadeError represents an error when events raised during callbacks cascade deeper than
+// the maximum depth of the Machine. Event is the first raised event that was not processed.
+type CascadeError[E comparable] struct {
+	Event    E
+	MaxDepth int
+}
+
+func (e *CascadeError[E]) Error() string {
+	return fmt.Sprintf("cascade error: raised events exceed the maximum depth of %d (event: %v)", e.MaxDepth, e.Event)
+}
+
+// SCXMLError represents an error when an SCXML document cannot be read as a Definition,
+// or a Definition cannot be written as SCXML
+type SCXMLError struct {
+	Msg string
+	Err error
+}
+
+func (e *SCXMLError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("scxml error: %s: %v", e.Msg, e.Err)
+	}
+	return fmt.Sprintf("scxml error: %s", e.Msg)
+}
+
+// Unwrap returns the underlying error, if any
+func (e *SCXMLError) Unwrap() error {
+	return e.Err
+}
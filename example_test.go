@@ -29,8 +29,7 @@ const (
 )
 
 type MusicPlayer struct {
-	stateMachine *zstate.StateMachine[PlayerState, PlayerEvent]
-	currentState PlayerState
+	machine *zstate.Machine[PlayerState, PlayerEvent]
 }
 
 func NewMusicPlayer() (*MusicPlayer, error) {
@@ -39,6 +38,7 @@ func NewMusicPlayer() (*MusicPlayer, error) {
 		AddState(Stopped).
 		AddState(Playing).
 		AddState(Paused).
+		SetInitial(Stopped).
 		AddTransition(Stopped, Playing, Play).
 		AddTransition(Playing, Paused, Pause).
 		AddTransition(Paused, Playing, Play).
@@ -50,24 +50,22 @@ func NewMusicPlayer() (*MusicPlayer, error) {
 		return nil, fmt.Errorf("failed to build state machine: %w", err)
 	}
 
+	machine, err := zstate.NewMachine(sm, Stopped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create machine: %w", err)
+	}
+
 	return &MusicPlayer{
-		stateMachine: sm,
+		machine: machine,
 	}, nil
 }
 
 func (mp *MusicPlayer) Trigger(event PlayerEvent) error {
-	ctx := context.Background()
-	currentState := mp.GetCurrentState()
-	nextState, err := mp.stateMachine.Trigger(ctx, currentState, event)
-	if err != nil {
-		return err
-	}
-	mp.currentState = nextState
-	return nil
+	return mp.machine.Fire(context.Background(), event)
 }
 
 func (mp *MusicPlayer) GetCurrentState() PlayerState {
-	return mp.currentState
+	return mp.machine.Current()
 }
 
 func Example() {
@@ -89,7 +87,7 @@ func Example() {
 		}
 	}
 
-	diagram, err := zstate.GenerateDiagram(player.stateMachine, zstate.MermaidFormat, Stopped)
+	diagram, err := zstate.GenerateDiagram(player.machine.StateMachine(), zstate.MermaidFormat, Stopped)
 	if err != nil {
 		log.Fatalf("Failed to generate diagram: %v", err)
 	}
@@ -104,12 +102,15 @@ func Example() {
 	// Action: Stop, New state: Stopped
 	// Diagram:
 	// stateDiagram-v2
+	//     classDef current fill:lightblue
+	//     [*] --> Stopped
 	//     Paused
 	//     Playing
-	//     Stopped : [*] Stopped
+	//     Stopped
 	//     Paused --> Playing : Play
 	//     Paused --> Stopped : Stop
 	//     Playing --> Paused : Pause
 	//     Playing --> Stopped : Stop
 	//     Stopped --> Playing : Play
+	//     class Stopped current
 }
@@ -30,6 +30,7 @@ func main() {
 		AddState(Closed).
 		AddState(Open).
 		AddState(Locked).
+		SetInitial(Closed).
 		AddTransition(Closed, Open, OpenDoor,
 			zstate.WithBefore[DoorState, DoorEvent](func(ctx context.Context, from, to DoorState, event DoorEvent) {
 				fmt.Println("[BeforeCallback] Before opening the door")
@@ -42,7 +43,9 @@ func main() {
 		AddTransition(Closed, Locked, LockDoor, zstate.WithGuard[DoorState, DoorEvent](func(ctx context.Context, from, to DoorState, event DoorEvent) bool {
 			return true
 		})).
-		AddTransition(Locked, Closed, UnlockDoor).
+		AddTransition(Locked, Closed, UnlockDoor, zstate.WithPayloadGuard(func(ctx context.Context, from, to DoorState, event DoorEvent, pin string) bool {
+			return pin == "1234"
+		})).
 		Build()
 
 	ctx := context.Background()
@@ -54,6 +57,16 @@ func main() {
 	}
 	fmt.Printf("New state: %v\n", newState)
 
+	newState, _ = door.Trigger(ctx, Closed, LockDoor)
+	for _, pin := range []string{"0000", "1234"} {
+		unlocked, err := door.TriggerWith(ctx, newState, UnlockDoor, pin)
+		if err != nil {
+			fmt.Printf("Unlock with PIN %v failed: %v\n", pin, err)
+			continue
+		}
+		fmt.Printf("Unlocked with PIN %v, new state: %v\n", pin, unlocked)
+	}
+
 	diagram, err := zstate.GenerateDiagram(door, zstate.MermaidFormat, Closed)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
@@ -27,34 +27,46 @@ const (
 )
 
 type MusicPlayer struct {
-	stateMachine *zstate.StateMachine[PlayerState, PlayerEvent]
+	machine      *zstate.Machine[PlayerState, PlayerEvent]
 	currentTrack int
-	currentState PlayerState
 }
 
 func NewMusicPlayer() (*MusicPlayer, error) {
 	builder := zstate.NewStateMachineBuilder[PlayerState, PlayerEvent]()
 	sm, err := builder.
 		AddState(Stopped).
-		AddState(Playing).
+		AddState(Playing,
+			zstate.OnEnter[PlayerState, PlayerEvent](func(ctx context.Context, from, to PlayerState, event PlayerEvent) {
+				log.Printf("Speaker on")
+			}),
+			zstate.OnExit[PlayerState, PlayerEvent](func(ctx context.Context, from, to PlayerState, event PlayerEvent) {
+				log.Printf("Speaker off")
+			}),
+		).
 		AddState(Paused).
-		AddTransition(Stopped, Playing, Play, zstate.WithBefore[PlayerState, PlayerEvent](logTransition)).
-		AddTransition(Playing, Paused, Pause, zstate.WithBefore[PlayerState, PlayerEvent](logTransition)).
-		AddTransition(Paused, Playing, Play, zstate.WithBefore[PlayerState, PlayerEvent](logTransition)).
-		AddTransition(Playing, Stopped, Stop, zstate.WithBefore[PlayerState, PlayerEvent](logTransition)).
-		AddTransition(Paused, Stopped, Stop, zstate.WithBefore[PlayerState, PlayerEvent](logTransition)).
-		AddTransition(Playing, Playing, Next, zstate.WithBefore[PlayerState, PlayerEvent](logTransition)).
-		AddTransition(Playing, Playing, Prev, zstate.WithBefore[PlayerState, PlayerEvent](logTransition)).
+		SetInitial(Stopped).
+		AddTransition(Stopped, Playing, Play).
+		AddTransition(Playing, Paused, Pause).
+		AddTransition(Paused, Playing, Play).
+		AddTransition(Playing, Stopped, Stop).
+		AddTransition(Paused, Stopped, Stop).
+		AddInternalTransition(Playing, Next).
+		AddInternalTransition(Playing, Prev).
+		OnTransition(logTransition).
 		Build()
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to build state machine: %w", err)
 	}
 
+	machine, err := zstate.NewMachine(sm, Stopped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create machine: %w", err)
+	}
+
 	return &MusicPlayer{
-		stateMachine: sm,
+		machine:      machine,
 		currentTrack: 0,
-		currentState: Stopped,
 	}, nil
 }
 
@@ -64,15 +76,12 @@ func logTransition(ctx context.Context, from, to PlayerState, event PlayerEvent)
 
 func (mp *MusicPlayer) Trigger(event PlayerEvent) error {
 	ctx := context.Background()
-	currentState := mp.GetCurrentState()
-	newState, err := mp.stateMachine.Trigger(ctx, currentState, event)
-	if err != nil {
+	if err := mp.machine.Fire(ctx, event); err != nil {
 		return fmt.Errorf("failed to trigger event %v: %w", event, err)
 	}
 
 	mp.handleSideEffects(event)
-	log.Printf("New state: %v", newState)
-	mp.currentState = newState
+	log.Printf("New state: %v", mp.GetCurrentState())
 	return nil
 }
 
@@ -90,7 +99,7 @@ func (mp *MusicPlayer) handleSideEffects(event PlayerEvent) {
 }
 
 func (mp *MusicPlayer) GetCurrentState() PlayerState {
-	return mp.currentState
+	return mp.machine.Current()
 }
 
 func main() {
@@ -112,7 +121,7 @@ func main() {
 	}
 
 	// Generate and print the state diagram
-	diagram, err := zstate.GenerateDiagram(player.stateMachine, zstate.MermaidFormat, player.GetCurrentState())
+	diagram, err := zstate.GenerateDiagram(player.machine.StateMachine(), zstate.MermaidFormat, player.GetCurrentState())
 	if err != nil {
 		log.Fatalf("Failed to generate diagram: %v", err)
 	}
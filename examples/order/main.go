@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+//go:generate go run github.com/upamune/zstate/cmd/zstategen -type Order order.json
+
+// shop implements OrderCallbacks
+type shop struct {
+	stock int
+}
+
+func (s *shop) InStock(ctx context.Context, from, to OrderState, event OrderEvent) bool {
+	return s.stock > 0
+}
+
+func (s *shop) PaymentAccepted(ctx context.Context, from, to OrderState, event OrderEvent) bool {
+	return true
+}
+
+func (s *shop) Charge(ctx context.Context, from, to OrderState, event OrderEvent) {
+	s.stock--
+	fmt.Println("[Charge] Charging the customer")
+}
+
+func (s *shop) Notify(ctx context.Context, from, to OrderState, event OrderEvent) {
+	fmt.Printf("[Notify] Order is %v\n", to)
+}
+
+func (s *shop) Refund(ctx context.Context, from, to OrderState, event OrderEvent) {
+	fmt.Printf("[Refund] Refunding the order cancelled while %v\n", from)
+}
+
+func main() {
+	builder, err := NewOrderBuilder(&shop{stock: 1})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	order, err := builder.Build()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	ctx := context.Background()
+	for _, events := range [][]OrderEvent{{Pay, Ship}, {Pay, Cancel}} {
+		current, _ := order.Initial()
+		for _, event := range events {
+			if current, err = order.Trigger(ctx, current, event); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			fmt.Printf("%v -> %v\n", event, current)
+		}
+	}
+}
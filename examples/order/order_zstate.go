@@ -0,0 +1,104 @@
+// Code generated by zstategen from order.json; DO NOT EDIT.
+
+package main
+
+import (
+	"context"
+
+	"github.com/upamune/zstate"
+)
+
+// OrderState is a state of the Order state machine
+type OrderState string
+
+const (
+	Pending     OrderState = "Pending"
+	Backordered OrderState = "Backordered"
+	Paid        OrderState = "Paid"
+	Shipped     OrderState = "Shipped"
+	Cancelled   OrderState = "Cancelled"
+)
+
+// String returns the name of the state
+func (s OrderState) String() string {
+	return string(s)
+}
+
+// OrderEvent is an event of the Order state machine
+type OrderEvent string
+
+const (
+	Pay     OrderEvent = "Pay"
+	Restock OrderEvent = "Restock"
+	Ship    OrderEvent = "Ship"
+	Cancel  OrderEvent = "Cancel"
+)
+
+// String returns the name of the event
+func (e OrderEvent) String() string {
+	return string(e)
+}
+
+// OrderCancelledActions lists the entry and exit actions of state Cancelled
+type OrderCancelledActions interface {
+	// Refund runs when entering Cancelled
+	Refund(ctx context.Context, from, to OrderState, event OrderEvent)
+	// Notify runs when entering Cancelled
+	Notify(ctx context.Context, from, to OrderState, event OrderEvent)
+}
+
+// OrderPendingToPaidOnPayCallbacks lists the guards and actions of the transition from Pending to Paid on Pay
+type OrderPendingToPaidOnPayCallbacks interface {
+	// InStock guards the transition
+	InStock(ctx context.Context, from, to OrderState, event OrderEvent) bool
+	// PaymentAccepted guards the transition
+	PaymentAccepted(ctx context.Context, from, to OrderState, event OrderEvent) bool
+	// Charge runs before the transition
+	Charge(ctx context.Context, from, to OrderState, event OrderEvent)
+	// Notify runs after the transition
+	Notify(ctx context.Context, from, to OrderState, event OrderEvent)
+}
+
+// OrderPaidToShippedOnShipCallbacks lists the guards and actions of the transition from Paid to Shipped on Ship
+type OrderPaidToShippedOnShipCallbacks interface {
+	// Notify runs after the transition
+	Notify(ctx context.Context, from, to OrderState, event OrderEvent)
+}
+
+// OrderCallbacks is implemented by the application to provide the guards and actions of the Order state machine
+type OrderCallbacks interface {
+	OrderCancelledActions
+	OrderPendingToPaidOnPayCallbacks
+	OrderPaidToShippedOnShipCallbacks
+}
+
+// NewOrderBuilder returns a builder populated with the states and transitions of the Order
+// state machine, using the methods of c as guards and actions. More states and transitions
+// can be added before building it.
+func NewOrderBuilder(c OrderCallbacks, opts ...zstate.BuilderOption) (zstate.StateMachineBuilder[OrderState, OrderEvent], error) {
+	ptr := func(s OrderState) *OrderState { return &s }
+	def := &zstate.Definition[OrderState, OrderEvent]{
+		Initial: ptr(Pending),
+		States: []zstate.StateDefinition[OrderState]{
+			{Name: Pending},
+			{Name: Backordered},
+			{Name: Paid},
+			{Name: Shipped, Final: true},
+			{Name: Cancelled, Final: true, OnEnter: []string{"refund", "notify"}},
+		},
+		Transitions: []zstate.TransitionDefinition[OrderState, OrderEvent]{
+			{From: Pending, To: Paid, Event: Pay, Guards: []string{"inStock", "paymentAccepted"}, Before: []string{"charge"}, After: []string{"notify"}},
+			{From: Pending, To: Backordered, Event: Pay, Default: true},
+			{From: Backordered, To: Pending, Event: Restock},
+			{From: Paid, To: Shipped, Event: Ship, After: []string{"notify"}},
+			{Any: true, To: Cancelled, Event: Cancel},
+		},
+	}
+	registry := zstate.NewRegistry[OrderState, OrderEvent]()
+	registry.RegisterGuard("inStock", c.InStock)
+	registry.RegisterGuard("paymentAccepted", c.PaymentAccepted)
+	registry.RegisterAction("refund", c.Refund)
+	registry.RegisterAction("notify", c.Notify)
+	registry.RegisterAction("charge", c.Charge)
+	return def.Builder(registry, opts...)
+}
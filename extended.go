@@ -0,0 +1,110 @@
+package zstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// DataGuard is a guard that inspects the extended state of an ExtendedStateMachine.
+// It receives a copy of the data and must not modify values the data refers to.
+type DataGuard[S, E comparable, D any] func(ctx context.Context, from, to S, event E, data D) bool
+
+// DataAction is a transition action that returns the updated extended state
+type DataAction[S, E comparable, D any] func(ctx context.Context, from, to S, event E, data D) D
+
+// WithDataGuard adds a guard function inspecting the extended state to a transition
+func WithDataGuard[S, E comparable, D any](guard DataGuard[S, E, D]) TransitionOption[S, E] {
+	return func(t *transition[S, E]) {
+		t.accepts = append(t.accepts, acceptData[D])
+		t.dataTypes = append(t.dataTypes, reflect.TypeFor[D]())
+		t.guards = append(t.guards, func(ctx context.Context, from, to S, event E, d *dispatch) error {
+			if !guard(ctx, from, to, event, dataAs[D](d.data)) {
+				return errGuardRejected
+			}
+			return nil
+		})
+	}
+}
+
+// WithDataAction adds an action updating the extended state to a transition.
+// Data actions run together with the before callbacks, in the order they were added,
+// and each one receives the data returned by the previous one.
+func WithDataAction[S, E comparable, D any](action DataAction[S, E, D]) TransitionOption[S, E] {
+	return func(t *transition[S, E]) {
+		t.accepts = append(t.accepts, acceptData[D])
+		t.dataTypes = append(t.dataTypes, reflect.TypeFor[D]())
+		t.befores = append(t.befores, func(ctx context.Context, from, to S, event E, d *dispatch) error {
+			d.data = action(ctx, from, to, event, dataAs[D](d.data))
+			return nil
+		})
+	}
+}
+
+// acceptData reports a DataError unless the data is nil or of type D
+func acceptData[D any](d *dispatch) error {
+	if d.data == nil {
+		return nil
+	}
+	if _, ok := d.data.(D); !ok {
+		return &DataError{Data: d.data, Want: reflect.TypeFor[D]().String()}
+	}
+	return nil
+}
+
+// dataAs returns data as a D, or the zero value of D if there is no data
+func dataAs[D any](data any) D {
+	v, _ := data.(D)
+	return v
+}
+
+// ExtendedStateMachine is a state machine that carries extended state of type D,
+// such as counters, alongside its enumerated state.
+// Guards added with WithDataGuard inspect the data and actions added with WithDataAction update it.
+type ExtendedStateMachine[S, E comparable, D any] struct {
+	sm *StateMachine[S, E]
+}
+
+// NewExtendedStateMachine creates an ExtendedStateMachine backed by sm.
+// Every data guard and action of sm must have been added for data of type D; transitions
+// with data options for another type are reported as TransitionError values joined into a single error.
+// When sm is triggered directly, data guards and actions receive the zero value of D.
+func NewExtendedStateMachine[S, E comparable, D any](sm *StateMachine[S, E]) (*ExtendedStateMachine[S, E, D], error) {
+	want := reflect.TypeFor[D]()
+	var errs []error
+	for _, t := range sm.declared {
+		for _, have := range t.dataTypes {
+			if have != want {
+				errs = append(errs, &TransitionError[S, E]{From: t.from, To: t.to, Event: t.event, Msg: fmt.Sprintf("data option expects %v, not %v", have, want)})
+				break
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return &ExtendedStateMachine[S, E, D]{sm: sm}, nil
+}
+
+// StateMachine returns the state machine definition backing the extended state machine
+func (x *ExtendedStateMachine[S, E, D]) StateMachine() *StateMachine[S, E] {
+	return x.sm
+}
+
+// Trigger attempts to perform a transition based on the given event and returns the new
+// state together with the updated data. If the transition fails, the current state and
+// the unchanged data are returned.
+func (x *ExtendedStateMachine[S, E, D]) Trigger(ctx context.Context, currentState S, data D, event E) (S, D, error) {
+	return x.TriggerWith(ctx, currentState, data, event, nil)
+}
+
+// TriggerWith works like Trigger for an event carrying a payload, as described for StateMachine.TriggerWith
+func (x *ExtendedStateMachine[S, E, D]) TriggerWith(ctx context.Context, currentState S, data D, event E, payload any) (S, D, error) {
+	d := &dispatch{payload: payload, data: data}
+	next, _, err := x.sm.trigger(ctx, currentState, event, d, nil)
+	if err != nil {
+		return currentState, data, err
+	}
+	return next, dataAs[D](d.data), nil
+}
@@ -0,0 +1,129 @@
+package zstate_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/upamune/zstate"
+)
+
+type Playlist struct {
+	Track  int
+	Tracks int
+}
+
+const Next PlayerEvent = "Next"
+
+func TestExtendedStateMachine(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("actions update data", func(t *testing.T) {
+		t.Parallel()
+		xsm := buildPlaylistStateMachine(t, nil)
+
+		state, data := Stopped, Playlist{Tracks: 2}
+		for _, tt := range []struct {
+			event PlayerEvent
+			state PlayerState
+			track int
+		}{
+			{Play, Playing, 0},
+			{Next, Playing, 1},
+			{Next, Stopped, 0}, // end of the playlist
+		} {
+			var err error
+			state, data, err = xsm.Trigger(ctx, state, data, tt.event)
+			if err != nil {
+				t.Fatalf("Unexpected error for %v: %v", tt.event, err)
+			}
+			if state != tt.state || data.Track != tt.track {
+				t.Errorf("Expected %v at track %d after %v, got %v at track %d", tt.state, tt.track, tt.event, state, data.Track)
+			}
+		}
+	})
+
+	t.Run("guards inspect data", func(t *testing.T) {
+		t.Parallel()
+		xsm := buildPlaylistStateMachine(t, nil)
+
+		_, _, err := xsm.Trigger(ctx, Stopped, Playlist{}, Play)
+		var guardErr *zstate.GuardError[PlayerState, PlayerEvent]
+		if !errors.As(err, &guardErr) {
+			t.Fatalf("Expected GuardError for an empty playlist, got %v", err)
+		}
+	})
+
+	t.Run("data is not committed on failure", func(t *testing.T) {
+		t.Parallel()
+		errSpeaker := errors.New("speaker unavailable")
+		xsm := buildPlaylistStateMachine(t, errSpeaker)
+
+		state, data, err := xsm.Trigger(ctx, Playing, Playlist{Track: 0, Tracks: 3}, Next)
+		if !errors.Is(err, errSpeaker) {
+			t.Fatalf("Expected error to wrap %v, got %v", errSpeaker, err)
+		}
+		if state != Playing || data.Track != 0 {
+			t.Errorf("Expected Playing at track 0, got %v at track %d", state, data.Track)
+		}
+	})
+
+	t.Run("data of the wrong type", func(t *testing.T) {
+		t.Parallel()
+		_, err := zstate.NewExtendedStateMachine[PlayerState, PlayerEvent, int](buildPlaylistStateMachine(t, nil).StateMachine())
+
+		var transitionErr *zstate.TransitionError[PlayerState, PlayerEvent]
+		if !errors.As(err, &transitionErr) || transitionErr.Msg != "data option expects zstate_test.Playlist, not int" {
+			t.Fatalf("Expected TransitionError for the Playlist options, got %v", err)
+		}
+		if n := strings.Count(err.Error(), "data option expects"); n != 3 {
+			t.Errorf("Expected an error for each of the 3 transitions with data options, got %d: %v", n, err)
+		}
+	})
+}
+
+func buildPlaylistStateMachine(t *testing.T, errSpeaker error) *zstate.ExtendedStateMachine[PlayerState, PlayerEvent, Playlist] {
+	t.Helper()
+
+	hasNext := func(ctx context.Context, from, to PlayerState, event PlayerEvent, p Playlist) bool {
+		return p.Track+1 < p.Tracks
+	}
+
+	builder := zstate.NewStateMachineBuilder[PlayerState, PlayerEvent]()
+	sm, err := builder.
+		AddState(Stopped).
+		AddState(Playing).
+		AddTransition(Stopped, Playing, Play, zstate.WithDataGuard(func(ctx context.Context, from, to PlayerState, event PlayerEvent, p Playlist) bool {
+			return p.Tracks > 0
+		})).
+		AddInternalTransition(Playing, Next,
+			zstate.WithDataGuard(hasNext),
+			zstate.WithDataAction(func(ctx context.Context, from, to PlayerState, event PlayerEvent, p Playlist) Playlist {
+				p.Track++
+				return p
+			}),
+			zstate.WithBeforeE[PlayerState, PlayerEvent](func(ctx context.Context, from, to PlayerState, event PlayerEvent) error {
+				return errSpeaker
+			}),
+		).
+		AddTransition(Playing, Stopped, Next,
+			zstate.AsDefault[PlayerState, PlayerEvent](),
+			zstate.WithDataAction(func(ctx context.Context, from, to PlayerState, event PlayerEvent, p Playlist) Playlist {
+				p.Track = 0
+				return p
+			}),
+		).
+		Build()
+
+	if err != nil {
+		t.Fatalf("Failed to build state machine: %v", err)
+	}
+	xsm, err := zstate.NewExtendedStateMachine[PlayerState, PlayerEvent, Playlist](sm)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return xsm
+}
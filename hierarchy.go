@@ -0,0 +1,66 @@
+package zstate
+
+// WithParent makes a state a child of the given parent state.
+// Children inherit the transitions of their ancestors: when a state has no transition
+// for an event, Trigger looks for one on its parent, then on the parent's parent, and so on.
+// Entry and exit actions run along the hierarchy path between the source and target states.
+func WithParent[S, E comparable](parent S) StateOption[S, E] {
+	return func(s *state[S, E]) {
+		s.parent = &parent
+	}
+}
+
+// Parent returns the parent of state s and whether s has a parent
+func (sm *StateMachine[S, E]) Parent(s S) (S, bool) {
+	if st := sm.states[s]; st != nil && st.parent != nil {
+		return *st.parent, true
+	}
+	var zero S
+	return zero, false
+}
+
+// ancestors returns s followed by its ancestors, from the innermost to the outermost
+func (sm *StateMachine[S, E]) ancestors(s S) []S {
+	chain := []S{s}
+	for {
+		parent, ok := sm.Parent(s)
+		if !ok {
+			return chain
+		}
+		chain = append(chain, parent)
+		s = parent
+	}
+}
+
+// path returns the states exited, innermost first, and the states entered, outermost first,
+// when a transition declared on from is taken from the current state to the target state to.
+// The transition is external, so a transition between a state and itself or one of its
+// ancestors or descendants exits and re-enters the outer state.
+func (sm *StateMachine[S, E]) path(current, from, to S) (exit, enter []S) {
+	// domain is the innermost proper ancestor shared by the source and target of t
+	var domain *S
+	sourceAncestors := make(map[S]struct{})
+	for _, s := range sm.ancestors(from)[1:] {
+		sourceAncestors[s] = struct{}{}
+	}
+	for _, s := range sm.ancestors(to)[1:] {
+		if _, ok := sourceAncestors[s]; ok {
+			domain = &s
+			break
+		}
+	}
+
+	for _, s := range sm.ancestors(current) {
+		if domain != nil && s == *domain {
+			break
+		}
+		exit = append(exit, s)
+	}
+	for _, s := range sm.ancestors(to) {
+		if domain != nil && s == *domain {
+			break
+		}
+		enter = append([]S{s}, enter...)
+	}
+	return exit, enter
+}
@@ -0,0 +1,161 @@
+package zstate_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/upamune/zstate"
+)
+
+type OrderState string
+
+const (
+	Active    OrderState = "Active"
+	Pending   OrderState = "Pending"
+	Paid      OrderState = "Paid"
+	Shipped   OrderState = "Shipped"
+	Cancelled OrderState = "Cancelled"
+)
+
+type OrderEvent string
+
+const (
+	Pay    OrderEvent = "Pay"
+	Ship   OrderEvent = "Ship"
+	Cancel OrderEvent = "Cancel"
+)
+
+func TestHierarchicalStates(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("children inherit parent transitions", func(t *testing.T) {
+		t.Parallel()
+		sm, _ := buildOrderStateMachine(t)
+
+		for _, from := range []OrderState{Pending, Paid} {
+			got, err := sm.Trigger(ctx, from, Cancel)
+			if err != nil {
+				t.Fatalf("Unexpected error cancelling from %v: %v", from, err)
+			}
+			if got != Cancelled {
+				t.Errorf("Expected state Cancelled from %v, got %v", from, got)
+			}
+		}
+
+		parent, ok := sm.Parent(Pending)
+		if !ok || parent != Active {
+			t.Errorf("Expected parent Active, got %v (ok: %v)", parent, ok)
+		}
+		if _, ok := sm.Parent(Active); ok {
+			t.Error("Expected Active to have no parent")
+		}
+	})
+
+	t.Run("child transitions take precedence", func(t *testing.T) {
+		t.Parallel()
+		sm, _ := buildOrderStateMachine(t)
+
+		got, err := sm.Trigger(ctx, Shipped, Cancel)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got != Shipped {
+			t.Errorf("Expected state Shipped, got %v", got)
+		}
+	})
+
+	t.Run("entry and exit actions follow the hierarchy", func(t *testing.T) {
+		t.Parallel()
+		sm, calls := buildOrderStateMachine(t)
+
+		state := Pending
+		for _, event := range []OrderEvent{Pay, Cancel} {
+			var err error
+			state, err = sm.Trigger(ctx, state, event)
+			if err != nil {
+				t.Fatalf("Unexpected error for %v: %v", event, err)
+			}
+		}
+
+		want := []string{
+			"exit Pending", "enter Paid",
+			"exit Paid", "exit Active", "enter Cancelled",
+		}
+		if !slices.Equal(*calls, want) {
+			t.Errorf("Expected calls %v, got %v", want, *calls)
+		}
+	})
+
+	t.Run("invalid hierarchy", func(t *testing.T) {
+		t.Parallel()
+		builder := zstate.NewStateMachineBuilder[OrderState, OrderEvent]()
+		_, err := builder.
+			AddState(Pending, zstate.WithParent[OrderState, OrderEvent](Active)).
+			AddState(Paid, zstate.WithParent[OrderState, OrderEvent](Shipped)).
+			AddState(Shipped, zstate.WithParent[OrderState, OrderEvent](Paid)).
+			Build()
+
+		var msgs []string
+		for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+			var stateErr *zstate.StateError[OrderState]
+			if !errors.As(e, &stateErr) {
+				t.Fatalf("Expected StateError, got %T: %v", e, e)
+			}
+			msgs = append(msgs, string(stateErr.State)+": "+stateErr.Msg)
+		}
+		want := []string{
+			"Pending: parent state Active is not declared",
+			"Paid: state hierarchy contains a cycle",
+			"Shipped: state hierarchy contains a cycle",
+		}
+		if !slices.Equal(msgs, want) {
+			t.Errorf("Expected errors %v, got %v", want, msgs)
+		}
+	})
+}
+
+func buildOrderStateMachine(t *testing.T) (*zstate.StateMachine[OrderState, OrderEvent], *[]string) {
+	t.Helper()
+
+	calls := &[]string{}
+	record := func(name string) zstate.TransitionCallback[OrderState, OrderEvent] {
+		return func(ctx context.Context, from, to OrderState, event OrderEvent) {
+			*calls = append(*calls, name)
+		}
+	}
+	child := func(name string) []zstate.StateOption[OrderState, OrderEvent] {
+		return []zstate.StateOption[OrderState, OrderEvent]{
+			zstate.WithParent[OrderState, OrderEvent](Active),
+			zstate.OnEnter(record("enter " + name)),
+			zstate.OnExit(record("exit " + name)),
+		}
+	}
+
+	builder := zstate.NewStateMachineBuilder[OrderState, OrderEvent](zstate.WithStrictValidation())
+	sm, err := builder.
+		AddState(Active,
+			zstate.OnEnter(record("enter Active")),
+			zstate.OnExit(record("exit Active")),
+		).
+		AddState(Pending, child("Pending")...).
+		AddState(Paid, child("Paid")...).
+		AddState(Shipped, child("Shipped")...).
+		AddFinalState(Cancelled, zstate.OnEnter(record("enter Cancelled"))).
+		SetInitial(Pending).
+		AddTransition(Pending, Paid, Pay).
+		AddTransition(Paid, Shipped, Ship).
+		AddTransition(Shipped, Shipped, Cancel, zstate.WithGuard[OrderState, OrderEvent](func(ctx context.Context, from, to OrderState, event OrderEvent) bool {
+			return true // Shipped orders can no longer be cancelled
+		})).
+		AddTransition(Active, Cancelled, Cancel).
+		Build()
+
+	if err != nil {
+		t.Fatalf("Failed to build state machine: %v", err)
+	}
+	return sm, calls
+}
@@ -0,0 +1,85 @@
+package zstate
+
+import (
+	"context"
+	"maps"
+)
+
+// HistoryKind represents the kind of a history pseudo-state
+type HistoryKind int
+
+const (
+	// NoHistory is the kind of regular transitions
+	NoHistory HistoryKind = iota
+	// ShallowHistory resumes the direct child of the group that was last active
+	ShallowHistory
+	// DeepHistory resumes the innermost state of the group that was last active
+	DeepHistory
+)
+
+// History records, for each history group, the innermost state that was active when
+// the group was last exited. It can be persisted alongside the current state.
+type History[S comparable] map[S]S
+
+// ToHistory turns a transition into a transition to the history pseudo-state of its target.
+// The target state is the history group: a state with child states. When the transition
+// is taken, it resumes the state that was last active in the group, as recorded in the
+// history passed to TriggerWithHistory. If nothing was recorded yet, the group itself is entered.
+func ToHistory[S, E comparable](kind HistoryKind) TransitionOption[S, E] {
+	return func(t *transition[S, E]) {
+		t.history = kind
+	}
+}
+
+// TriggerWithHistory works like Trigger but also resolves history transitions against h and
+// records the state that was active in every history group the transition exits.
+// The updated history is returned as a new value; h itself is not modified.
+func (sm *StateMachine[S, E]) TriggerWithHistory(ctx context.Context, currentState S, h History[S], event E) (S, History[S], error) {
+	newState, next, _, err := sm.triggerWithHistory(ctx, currentState, h, event, &dispatch{})
+	return newState, next, err
+}
+
+// triggerWithHistory implements TriggerWithHistory for an event accompanied by d.
+// It also returns the transition that was taken.
+func (sm *StateMachine[S, E]) triggerWithHistory(ctx context.Context, currentState S, h History[S], event E, d *dispatch) (S, History[S], *transition[S, E], error) {
+	next := maps.Clone(h)
+	if next == nil {
+		next = make(History[S])
+	}
+	newState, t, err := sm.trigger(ctx, currentState, event, d, next)
+	if err != nil {
+		return currentState, h, nil, err
+	}
+	return newState, next, t, nil
+}
+
+// target returns the state transition t leads to, resolving history transitions against h
+func (sm *StateMachine[S, E]) target(t *transition[S, E], h History[S]) S {
+	last, ok := h[t.to]
+	if t.history == NoHistory || !ok {
+		return t.to
+	}
+	if t.history == DeepHistory {
+		return last
+	}
+
+	// Shallow history resumes the child of the group on the path to the last active state
+	ancestors := sm.ancestors(last)
+	for i, s := range ancestors[1:] {
+		if s == t.to {
+			return ancestors[i]
+		}
+	}
+	return t.to
+}
+
+// historyGroups returns the states targeted by history transitions
+func (b *stateMachineBuilder[S, E]) historyGroups() map[S]struct{} {
+	groups := make(map[S]struct{})
+	for _, t := range b.declared {
+		if t.history != NoHistory {
+			groups[t.to] = struct{}{}
+		}
+	}
+	return groups
+}
@@ -0,0 +1,168 @@
+package zstate_test
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+
+	"github.com/upamune/zstate"
+)
+
+type TaskState string
+
+const (
+	Working    TaskState = "Working"
+	Editing    TaskState = "Editing"
+	Reviewing  TaskState = "Reviewing"
+	Reading    TaskState = "Reading"
+	Commenting TaskState = "Commenting"
+	Suspended  TaskState = "Suspended"
+)
+
+type TaskEvent string
+
+const (
+	Submit     TaskEvent = "Submit"
+	Comment    TaskEvent = "Comment"
+	Interrupt  TaskEvent = "Interrupt"
+	Resume     TaskEvent = "Resume"
+	ResumeDeep TaskEvent = "ResumeDeep"
+)
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("shallow and deep history", func(t *testing.T) {
+		t.Parallel()
+		sm := buildTaskStateMachine(t)
+
+		state, history, err := sm.TriggerWithHistory(ctx, Commenting, nil, Interrupt)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if state != Suspended {
+			t.Fatalf("Expected state Suspended, got %v", state)
+		}
+		if want := (zstate.History[TaskState]{Working: Commenting}); !maps.Equal(history, want) {
+			t.Fatalf("Expected history %v, got %v", want, history)
+		}
+
+		for _, tt := range []struct {
+			event TaskEvent
+			want  TaskState
+		}{
+			{Resume, Reviewing},
+			{ResumeDeep, Commenting},
+		} {
+			got, _, err := sm.TriggerWithHistory(ctx, Suspended, history, tt.event)
+			if err != nil {
+				t.Fatalf("Unexpected error for %v: %v", tt.event, err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected state %v for %v, got %v", tt.want, tt.event, got)
+			}
+		}
+	})
+
+	t.Run("without recorded history", func(t *testing.T) {
+		t.Parallel()
+		sm := buildTaskStateMachine(t)
+
+		got, err := sm.Trigger(ctx, Suspended, Resume)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got != Working {
+			t.Errorf("Expected state Working, got %v", got)
+		}
+	})
+
+	t.Run("history is a value", func(t *testing.T) {
+		t.Parallel()
+		sm := buildTaskStateMachine(t)
+
+		history := zstate.History[TaskState]{Working: Editing}
+		_, next, err := sm.TriggerWithHistory(ctx, Reading, history, Interrupt)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if history[Working] != Editing {
+			t.Errorf("Expected original history to be unchanged, got %v", history)
+		}
+		if next[Working] != Reading {
+			t.Errorf("Expected new history to record Reading, got %v", next)
+		}
+	})
+
+	t.Run("Machine records and restores history", func(t *testing.T) {
+		t.Parallel()
+		sm := buildTaskStateMachine(t)
+
+		m, err := zstate.NewMachine(sm, Editing)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for _, event := range []TaskEvent{Submit, Interrupt} {
+			if err := m.Fire(ctx, event); err != nil {
+				t.Fatalf("Unexpected error for %v: %v", event, err)
+			}
+		}
+
+		current, history := m.Current(), m.History()
+		restored, err := zstate.NewMachine(sm, Editing)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := restored.Restore(current, history); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := restored.Fire(ctx, ResumeDeep); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if restored.Current() != Reading {
+			t.Errorf("Expected state Reading, got %v", restored.Current())
+		}
+	})
+
+	t.Run("history group without children", func(t *testing.T) {
+		t.Parallel()
+		builder := zstate.NewStateMachineBuilder[TaskState, TaskEvent]()
+		_, err := builder.
+			AddState(Editing).
+			AddState(Suspended).
+			AddTransition(Suspended, Editing, Resume, zstate.ToHistory[TaskState, TaskEvent](zstate.ShallowHistory)).
+			Build()
+		var transitionErr *zstate.TransitionError[TaskState, TaskEvent]
+		if !errors.As(err, &transitionErr) {
+			t.Fatalf("Expected TransitionError, got %v", err)
+		}
+	})
+}
+
+func buildTaskStateMachine(t *testing.T) *zstate.StateMachine[TaskState, TaskEvent] {
+	t.Helper()
+
+	builder := zstate.NewStateMachineBuilder[TaskState, TaskEvent](zstate.WithStrictValidation())
+	sm, err := builder.
+		AddState(Working).
+		AddState(Editing, zstate.WithParent[TaskState, TaskEvent](Working)).
+		AddState(Reviewing, zstate.WithParent[TaskState, TaskEvent](Working)).
+		AddState(Reading, zstate.WithParent[TaskState, TaskEvent](Reviewing)).
+		AddState(Commenting, zstate.WithParent[TaskState, TaskEvent](Reviewing)).
+		AddState(Suspended).
+		SetInitial(Editing).
+		AddTransition(Editing, Reading, Submit).
+		AddTransition(Reading, Commenting, Comment).
+		AddTransition(Working, Suspended, Interrupt).
+		AddTransition(Suspended, Working, Resume, zstate.ToHistory[TaskState, TaskEvent](zstate.ShallowHistory)).
+		AddTransition(Suspended, Working, ResumeDeep, zstate.ToHistory[TaskState, TaskEvent](zstate.DeepHistory)).
+		Build()
+
+	if err != nil {
+		t.Fatalf("Failed to build state machine: %v", err)
+	}
+	return sm
+}
@@ -0,0 +1,73 @@
+package zstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// GuardRejectedListener is a function type for listeners notified when the guards reject every candidate transition for an event
+type GuardRejectedListener[S, E comparable] func(ctx context.Context, err *GuardError[S, E])
+
+// NoTransitionListener is a function type for listeners notified when no transition is declared for an event
+type NoTransitionListener[S, E comparable] func(ctx context.Context, err *NoTransitionError[S, E])
+
+// listeners holds the machine-wide listeners registered on the builder
+type listeners[S, E comparable] struct {
+	transition    []TransitionCallback[S, E]
+	guardRejected []GuardRejectedListener[S, E]
+	noTransition  []NoTransitionListener[S, E]
+}
+
+// OnTransition registers a listener notified after every completed transition,
+// including internal transitions, once the after callbacks have run.
+// Listeners run in the order they were registered.
+func (b *stateMachineBuilder[S, E]) OnTransition(listener TransitionCallback[S, E]) StateMachineBuilder[S, E] {
+	b.listeners.transition = append(b.listeners.transition, listener)
+	return b
+}
+
+// OnGuardRejected registers a listener notified whenever an event is rejected because
+// the guards of every candidate transition failed
+func (b *stateMachineBuilder[S, E]) OnGuardRejected(listener GuardRejectedListener[S, E]) StateMachineBuilder[S, E] {
+	b.listeners.guardRejected = append(b.listeners.guardRejected, listener)
+	return b
+}
+
+// OnNoTransition registers a listener notified whenever an event has no transition from the current state
+func (b *stateMachineBuilder[S, E]) OnNoTransition(listener NoTransitionListener[S, E]) StateMachineBuilder[S, E] {
+	b.listeners.noTransition = append(b.listeners.noTransition, listener)
+	return b
+}
+
+// notifyTransition notifies the transition listeners and the metrics of a completed transition
+func (sm *StateMachine[S, E]) notifyTransition(ctx context.Context, from, to S, event E) {
+	if sm.metrics != nil {
+		sm.metrics.Transition(fmt.Sprint(from), fmt.Sprint(to), fmt.Sprint(event))
+	}
+	for _, listener := range sm.listeners.transition {
+		listener(ctx, from, to, event)
+	}
+}
+
+// notifyRejected notifies the listeners matching err, an error returned by resolve, and the metrics
+func (sm *StateMachine[S, E]) notifyRejected(ctx context.Context, err error) {
+	var guardErr *GuardError[S, E]
+	var noTransitionErr *NoTransitionError[S, E]
+	switch {
+	case errors.As(err, &guardErr):
+		if sm.metrics != nil {
+			sm.metrics.GuardRejected(fmt.Sprint(guardErr.From), fmt.Sprint(guardErr.Event))
+		}
+		for _, listener := range sm.listeners.guardRejected {
+			listener(ctx, guardErr)
+		}
+	case errors.As(err, &noTransitionErr):
+		if sm.metrics != nil {
+			sm.metrics.NoTransition(fmt.Sprint(noTransitionErr.From), fmt.Sprint(noTransitionErr.Event))
+		}
+		for _, listener := range sm.listeners.noTransition {
+			listener(ctx, noTransitionErr)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package zstate_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/upamune/zstate"
+)
+
+func TestListeners(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("every attempt", func(t *testing.T) {
+		t.Parallel()
+		var events []string
+		sm := buildListenedJobStateMachine(t, &events, nil)
+
+		if _, err := sm.Trigger(ctx, Queued, Start); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := sm.Trigger(ctx, Running, Hold); err == nil {
+			t.Fatal("Expected guard error, got nil")
+		}
+		if _, err := sm.Trigger(ctx, Queued, Complete); err == nil {
+			t.Fatal("Expected no transition error, got nil")
+		}
+		if _, err := sm.Trigger(ctx, Running, Requeue); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		want := []string{
+			"first Queued -> Running (Start)",
+			"second Queued -> Running (Start)",
+			"rejected Running -> Held (Hold)",
+			"unhandled Queued (Complete)",
+			"first Running -> Running (Requeue)",
+			"second Running -> Running (Requeue)",
+		}
+		if !slices.Equal(events, want) {
+			t.Errorf("Expected events %v, got %v", want, events)
+		}
+	})
+
+	t.Run("after callbacks run first", func(t *testing.T) {
+		t.Parallel()
+		var events []string
+		sm := buildListenedJobStateMachine(t, &events, func(ctx context.Context, from, to JobState, event JobEvent) {
+			events = append(events, "after")
+		})
+
+		if _, err := sm.Trigger(ctx, Queued, Start); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		want := []string{"after", "first Queued -> Running (Start)", "second Queued -> Running (Start)"}
+		if !slices.Equal(events, want) {
+			t.Errorf("Expected events %v, got %v", want, events)
+		}
+	})
+
+	t.Run("failed before callback", func(t *testing.T) {
+		t.Parallel()
+		var events []string
+		builder := zstate.NewStateMachineBuilder[JobState, JobEvent]()
+		sm, err := builder.
+			AddState(Queued).
+			AddState(Running).
+			AddTransition(Queued, Running, Start, zstate.WithBeforeE(func(ctx context.Context, from, to JobState, event JobEvent) error {
+				return errors.New("no worker available")
+			})).
+			OnTransition(func(ctx context.Context, from, to JobState, event JobEvent) {
+				events = append(events, "transition")
+			}).
+			OnGuardRejected(func(ctx context.Context, err *zstate.GuardError[JobState, JobEvent]) {
+				events = append(events, "rejected")
+			}).
+			OnNoTransition(func(ctx context.Context, err *zstate.NoTransitionError[JobState, JobEvent]) {
+				events = append(events, "unhandled")
+			}).
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+
+		if _, err := sm.Trigger(ctx, Queued, Start); err == nil {
+			t.Fatal("Expected transition error, got nil")
+		}
+		if len(events) != 0 {
+			t.Errorf("Expected no listener to be notified, got %v", events)
+		}
+	})
+
+	t.Run("machine", func(t *testing.T) {
+		t.Parallel()
+		var events []string
+		sm := buildListenedJobStateMachine(t, &events, nil)
+
+		m, err := zstate.NewMachine(sm, Queued)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := m.Fire(ctx, Start); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := m.Fire(ctx, Start); err == nil {
+			t.Fatal("Expected no transition error, got nil")
+		}
+
+		want := []string{
+			"first Queued -> Running (Start)",
+			"second Queued -> Running (Start)",
+			"unhandled Running (Start)",
+		}
+		if !slices.Equal(events, want) {
+			t.Errorf("Expected events %v, got %v", want, events)
+		}
+	})
+}
+
+// buildListenedJobStateMachine builds a job state machine whose listeners record their notifications in events.
+// Two transition listeners are registered to check that they run in order.
+func buildListenedJobStateMachine(t *testing.T, events *[]string, after zstate.TransitionCallback[JobState, JobEvent]) *zstate.StateMachine[JobState, JobEvent] {
+	t.Helper()
+
+	var opts []zstate.TransitionOption[JobState, JobEvent]
+	if after != nil {
+		opts = append(opts, zstate.WithAfter(after))
+	}
+
+	record := func(name string) zstate.TransitionCallback[JobState, JobEvent] {
+		return func(ctx context.Context, from, to JobState, event JobEvent) {
+			*events = append(*events, fmt.Sprintf("%s %v -> %v (%v)", name, from, to, event))
+		}
+	}
+
+	builder := zstate.NewStateMachineBuilder[JobState, JobEvent]()
+	sm, err := builder.
+		AddState(Queued).
+		AddState(Running).
+		AddState(Held).
+		AddTransition(Queued, Running, Start, opts...).
+		AddTransition(Running, Held, Hold, zstate.WithGuard(func(ctx context.Context, from, to JobState, event JobEvent) bool {
+			return false
+		})).
+		AddInternalTransition(Running, Requeue).
+		OnTransition(record("first")).
+		OnTransition(record("second")).
+		OnGuardRejected(func(ctx context.Context, err *zstate.GuardError[JobState, JobEvent]) {
+			*events = append(*events, fmt.Sprintf("rejected %v -> %v (%v)", err.From, err.To, err.Event))
+		}).
+		OnNoTransition(func(ctx context.Context, err *zstate.NoTransitionError[JobState, JobEvent]) {
+			*events = append(*events, fmt.Sprintf("unhandled %v (%v)", err.From, err.Event))
+		}).
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build state machine: %v", err)
+	}
+	return sm
+}
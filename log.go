@@ -0,0 +1,94 @@
+package zstate
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Outcomes of a triggered event reported in logs and traces
+const (
+	outcomeTransitioned  = "transitioned"
+	outcomeGuardRejected = "guard_rejected"
+	outcomeNoTransition  = "no_transition"
+	outcomeFailed        = "failed"
+)
+
+// LogLevels sets the levels of the records written by a state machine configured with WithLogger.
+// The zero value logs every record at slog.LevelInfo.
+type LogLevels struct {
+	// Transition is the level of completed transitions
+	Transition slog.Level
+	// Rejected is the level of events rejected by guards or without a transition
+	Rejected slog.Level
+	// Failed is the level of transitions aborted by a before callback
+	Failed slog.Level
+}
+
+// WithLogger makes the state machine write a record to logger for every triggered event.
+// Each record has the message "trigger" and the attributes from, to, event, outcome and duration;
+// rejected and failed events also carry the error, and guard rejections the index of the failing guard.
+// The outcome is one of "transitioned", "guard_rejected", "no_transition" and "failed".
+// States and events are logged with slog.Any, so types implementing slog.LogValuer control how they render.
+func WithLogger(logger *slog.Logger, levels LogLevels) BuilderOption {
+	return func(c *builderConfig) {
+		c.logger = logger
+		c.logLevels = levels
+	}
+}
+
+// logAttempt writes the record of an event triggered from the current state at start.
+// to is the new state if err is nil.
+func (sm *StateMachine[S, E]) logAttempt(ctx context.Context, currentState S, event E, start time.Time, to S, err error) {
+	if sm.logger == nil {
+		return
+	}
+
+	level := sm.logLevels.Transition
+	attrs := []slog.Attr{slog.Any("from", currentState)}
+	var guardErr *GuardError[S, E]
+	var noTransitionErr *NoTransitionError[S, E]
+	var transitionErr *TransitionError[S, E]
+	switch {
+	case err == nil:
+		attrs = append(attrs, slog.Any("to", to), slog.Any("event", event), slog.String("outcome", outcomeTransitioned))
+	case errors.As(err, &guardErr):
+		level = sm.logLevels.Rejected
+		attrs = append(attrs, slog.Any("to", guardErr.To), slog.Any("event", event), slog.String("outcome", outcomeGuardRejected), slog.Int("guard", guardErr.Index))
+	case errors.As(err, &noTransitionErr):
+		level = sm.logLevels.Rejected
+		attrs = append(attrs, slog.Any("event", event), slog.String("outcome", outcomeNoTransition))
+	case errors.As(err, &transitionErr):
+		level = sm.logLevels.Failed
+		attrs = append(attrs, slog.Any("to", transitionErr.To), slog.Any("event", event), slog.String("outcome", outcomeFailed))
+	default:
+		level = sm.logLevels.Failed
+		attrs = append(attrs, slog.Any("event", event), slog.String("outcome", outcomeFailed))
+	}
+	if !sm.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs = append(attrs, slog.Duration("duration", time.Since(start)))
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	sm.logger.LogAttrs(ctx, level, "trigger", attrs...)
+}
+
+// outcomeOf returns the outcome of an event whose trigger returned err
+func outcomeOf[S, E comparable](err error) string {
+	var guardErr *GuardError[S, E]
+	var noTransitionErr *NoTransitionError[S, E]
+	switch {
+	case err == nil:
+		return outcomeTransitioned
+	case errors.As(err, &guardErr):
+		return outcomeGuardRejected
+	case errors.As(err, &noTransitionErr):
+		return outcomeNoTransition
+	default:
+		return outcomeFailed
+	}
+}
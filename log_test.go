@@ -0,0 +1,142 @@
+package zstate_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"github.com/upamune/zstate"
+)
+
+// Ticket is a state that renders itself as a group through slog.LogValuer
+type Ticket struct {
+	Queue  string
+	Status string
+}
+
+func (t Ticket) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("queue", t.Queue), slog.String("status", t.Status))
+}
+
+var (
+	ticketOpen     = Ticket{Queue: "support", Status: "open"}
+	ticketAssigned = Ticket{Queue: "support", Status: "assigned"}
+	ticketClosed   = Ticket{Queue: "support", Status: "closed"}
+)
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("records", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		sm := buildLoggedTicketStateMachine(t, &buf, slog.LevelDebug, zstate.LogLevels{Transition: slog.LevelDebug, Rejected: slog.LevelWarn, Failed: slog.LevelError})
+
+		if _, err := sm.Trigger(ctx, ticketOpen, "assign"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := sm.Trigger(ctx, ticketAssigned, "close"); err == nil {
+			t.Fatal("Expected guard error, got nil")
+		}
+		if _, err := sm.Trigger(ctx, ticketClosed, "assign"); err == nil {
+			t.Fatal("Expected no transition error, got nil")
+		}
+		if _, err := sm.Trigger(ctx, ticketAssigned, "escalate"); err == nil {
+			t.Fatal("Expected transition error, got nil")
+		}
+
+		group := func(status string) map[string]any {
+			return map[string]any{"queue": "support", "status": status}
+		}
+		want := []map[string]any{
+			{"level": "DEBUG", "msg": "trigger", "from": group("open"), "to": group("assigned"), "event": "assign", "outcome": "transitioned"},
+			{"level": "WARN", "msg": "trigger", "from": group("assigned"), "to": group("closed"), "event": "close", "outcome": "guard_rejected", "guard": float64(0), "error": "guard error: condition not met (from: {support assigned}, to: {support closed}, event: close)"},
+			{"level": "WARN", "msg": "trigger", "from": group("closed"), "event": "assign", "outcome": "no_transition", "error": "no transition error: no transition found (from: {support closed}, event: assign)"},
+			{"level": "ERROR", "msg": "trigger", "from": group("assigned"), "to": group("open"), "event": "escalate", "outcome": "failed", "error": "transition error: before callback failed: no agent available (from: {support assigned}, to: {support open}, event: escalate)"},
+		}
+
+		got := decodeRecords(t, &buf)
+		if len(got) != len(want) {
+			t.Fatalf("Expected %d records, got %d: %v", len(want), len(got), got)
+		}
+		for i := range want {
+			if !reflect.DeepEqual(got[i], want[i]) {
+				t.Errorf("Expected record %v, got %v", want[i], got[i])
+			}
+		}
+	})
+
+	t.Run("disabled level", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		sm := buildLoggedTicketStateMachine(t, &buf, slog.LevelInfo, zstate.LogLevels{Transition: slog.LevelDebug, Rejected: slog.LevelWarn})
+
+		if _, err := sm.Trigger(ctx, ticketOpen, "assign"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := sm.Trigger(ctx, ticketClosed, "assign"); err == nil {
+			t.Fatal("Expected no transition error, got nil")
+		}
+
+		got := decodeRecords(t, &buf)
+		if len(got) != 1 || got[0]["outcome"] != "no_transition" {
+			t.Errorf("Expected only the no_transition record, got %v", got)
+		}
+	})
+}
+
+// buildLoggedTicketStateMachine builds a ticket state machine logging to buf through a JSON handler
+// with the given minimum level. Time and duration are removed from the records to keep them stable.
+func buildLoggedTicketStateMachine(t *testing.T, buf *bytes.Buffer, minLevel slog.Level, levels zstate.LogLevels) *zstate.StateMachine[Ticket, string] {
+	t.Helper()
+
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{
+		Level: minLevel,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == "duration") {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	builder := zstate.NewStateMachineBuilder[Ticket, string](zstate.WithLogger(logger, levels))
+	sm, err := builder.
+		AddState(ticketOpen).
+		AddState(ticketAssigned).
+		AddState(ticketClosed).
+		AddTransition(ticketOpen, ticketAssigned, "assign").
+		AddTransition(ticketAssigned, ticketClosed, "close", zstate.WithGuard(func(ctx context.Context, from, to Ticket, event string) bool {
+			return false
+		})).
+		AddTransition(ticketAssigned, ticketOpen, "escalate", zstate.WithBeforeE(func(ctx context.Context, from, to Ticket, event string) error {
+			return errors.New("no agent available")
+		})).
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build state machine: %v", err)
+	}
+	return sm
+}
+
+// decodeRecords decodes the JSON records written to buf
+func decodeRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var records []map[string]any
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var r map[string]any
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		records = append(records, r)
+	}
+	return records
+}
@@ -0,0 +1,291 @@
+package zstate
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Machine is a state machine instance that keeps track of its current state.
+// It is created from a built StateMachine, which can be shared by many instances.
+//
+// A Machine is safe for concurrent use. Events are processed one at a time:
+// the guard, before and after callbacks of one event complete before the next
+// event is evaluated. Callbacks must therefore not call Fire on the machine
+// that invoked them; they can queue follow-up events with Raise instead.
+//
+// A Machine fires the timeouts added with AddTimeout while it stays in their states.
+//
+// When the machine is in a parallel state, its current state is the parallel state and it
+// keeps the active state of every region in its configuration. Events are then dispatched
+// to the regions as described for TriggerParallel.
+type Machine[S, E comparable] struct {
+	mu      sync.Mutex
+	sm      *StateMachine[S, E]
+	current S
+	// cfg is the configuration of the current state if it is a parallel state, or nil
+	cfg     Configuration[S]
+	history History[S]
+	clock   Clock
+	// pending holds the scheduled timeouts of every active state
+	pending map[S][]*pendingTimeout
+	// maxCascadeDepth limits how deeply events raised during callbacks may cascade
+	maxCascadeDepth int
+}
+
+// pendingTimeout is a timeout scheduled by a Machine
+type pendingTimeout struct {
+	timer Timer
+}
+
+// MachineOption is a function type for configuring a Machine
+type MachineOption func(*machineConfig)
+
+type machineConfig struct {
+	clock           Clock
+	maxCascadeDepth int
+}
+
+// WithClock sets the clock used to schedule timeouts
+func WithClock(clock Clock) MachineOption {
+	return func(c *machineConfig) {
+		c.clock = clock
+	}
+}
+
+// NewMachine creates a new Machine backed by sm, starting in the initial state.
+// Starting in a parallel state starts every region in its initial state, and starting in
+// a state of a region starts the parallel state with the other regions in their initial state.
+func NewMachine[S, E comparable](sm *StateMachine[S, E], initial S, opts ...MachineOption) (*Machine[S, E], error) {
+	if _, ok := sm.states[initial]; !ok {
+		return nil, &StateError[S]{State: initial, Msg: "initial state is not declared"}
+	}
+
+	config := machineConfig{clock: realClock{}, maxCascadeDepth: defaultMaxCascadeDepth}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	current, cfg := sm.settle(initial)
+	m := &Machine[S, E]{
+		sm:              sm,
+		current:         current,
+		cfg:             cfg,
+		clock:           config.clock,
+		pending:         make(map[S][]*pendingTimeout),
+		maxCascadeDepth: config.maxCascadeDepth,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.schedule(m.active())
+	return m, nil
+}
+
+// Current returns the current state of the machine
+func (m *Machine[S, E]) Current() S {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.current
+}
+
+// Configuration returns a copy of the configuration of the current state if it is a parallel state, or nil
+func (m *Machine[S, E]) Configuration() Configuration[S] {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.cfg)
+}
+
+// History returns a copy of the history recorded by the machine.
+// It can be persisted alongside the current state and passed to Restore later.
+func (m *Machine[S, E]) History() History[S] {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return maps.Clone(m.history)
+}
+
+// Restore sets the current state and the recorded history of the machine,
+// typically to values previously returned by Current and History.
+// Restoring a parallel state, or a state of one of its regions, starts the regions
+// as described for NewMachine; use RestoreConfiguration to restore every region.
+// The timeouts of the restored state start over.
+func (m *Machine[S, E]) Restore(current S, h History[S]) error {
+	if _, ok := m.sm.states[current]; !ok {
+		return &StateError[S]{State: current, Msg: "state is not declared"}
+	}
+
+	state, cfg := m.sm.settle(current)
+	m.restore(state, cfg, h)
+	return nil
+}
+
+// RestoreConfiguration sets the current state of the machine to the parallel state parent
+// with the configuration cfg, together with the recorded history, typically to values
+// previously returned by Current, Configuration and History.
+// The timeouts of the restored states start over.
+func (m *Machine[S, E]) RestoreConfiguration(parent S, cfg Configuration[S], h History[S]) error {
+	regions := m.sm.regions[parent]
+	if len(regions) == 0 {
+		return &StateError[S]{State: parent, Msg: "state has no regions"}
+	}
+	if len(cfg) != len(regions) {
+		return &StateError[S]{State: parent, Msg: "configuration does not match the regions of the state"}
+	}
+	for i, s := range cfg {
+		if _, ok := m.sm.states[s]; !ok || m.sm.regionOf(parent, s) != i {
+			return &StateError[S]{State: s, Msg: "state is not in the region of the configuration"}
+		}
+	}
+
+	m.restore(parent, slices.Clone(cfg), h)
+	return nil
+}
+
+// restore replaces the state of the machine and restarts the timeouts
+func (m *Machine[S, E]) restore(current S, cfg Configuration[S], h History[S]) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cancel(m.active())
+	m.current = current
+	m.cfg = cfg
+	m.history = maps.Clone(h)
+	m.schedule(m.active())
+}
+
+// StateMachine returns the state machine definition backing the machine
+func (m *Machine[S, E]) StateMachine() *StateMachine[S, E] {
+	return m.sm
+}
+
+// Fire triggers the given event and moves the machine to the resulting state.
+// The current state is left unchanged if the transition fails.
+//
+// Events raised by the callbacks with Raise are processed after the
+// transition has completed, in the order they were raised, before Fire returns.
+// If one of them fails, the remaining raised events are discarded and Fire returns
+// its error; the machine keeps the state reached by the events processed before it.
+func (m *Machine[S, E]) Fire(ctx context.Context, event E) error {
+	return m.FireWith(ctx, event, nil)
+}
+
+// FireWith works like Fire for an event carrying a payload, as described for TriggerWith
+func (m *Machine[S, E]) FireWith(ctx context.Context, event E, payload any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.fire(ctx, event, &dispatch{payload: payload})
+}
+
+// fire triggers event followed by the events raised while processing it; the caller must hold m.mu
+func (m *Machine[S, E]) fire(ctx context.Context, event E, d *dispatch) error {
+	q := &eventQueue[E]{}
+	defer func() { q.closed = true }()
+	ctx = context.WithValue(ctx, queueKey{}, q)
+
+	if err := m.step(ctx, event, d); err != nil {
+		return err
+	}
+	for len(q.events) > 0 {
+		next := q.events[0]
+		q.events = q.events[1:]
+		if next.depth > m.maxCascadeDepth {
+			return &CascadeError[E]{Event: next.event, MaxDepth: m.maxCascadeDepth}
+		}
+		q.depth = next.depth
+		if err := m.step(ctx, next.event, &dispatch{payload: next.payload}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// step triggers a single event and updates the timeouts; the caller must hold m.mu
+func (m *Machine[S, E]) step(ctx context.Context, event E, d *dispatch) error {
+	if m.cfg != nil {
+		history := maps.Clone(m.history)
+		if history == nil {
+			history = make(History[S])
+		}
+		next, err := m.sm.triggerParallel(ctx, m.current, m.cfg, event, d, history)
+		if err != nil {
+			return err
+		}
+		m.advance(next.state, next.cfg, history, next.exit, next.enter)
+		return nil
+	}
+
+	next, history, t, err := m.sm.triggerWithHistory(ctx, m.current, m.history, event, d)
+	if err != nil {
+		return err
+	}
+	exit, enter := m.sm.steps(m.current, t, next)
+	state, cfg := m.sm.settle(next)
+	m.advance(state, cfg, history, exit, enter)
+	return nil
+}
+
+// advance moves the machine to the given state and updates the timeouts of the states
+// exited and entered; the caller must hold m.mu
+func (m *Machine[S, E]) advance(current S, cfg Configuration[S], history History[S], exit, enter []S) {
+	m.cancel(exit)
+	m.current = current
+	m.cfg = cfg
+	m.history = history
+	m.schedule(enter)
+}
+
+// active returns the current state and its ancestors from the outermost inwards,
+// followed by the active states of every region from the outermost inwards
+func (m *Machine[S, E]) active() []S {
+	states := m.sm.ancestors(m.current)
+	slices.Reverse(states)
+	for _, s := range m.cfg {
+		var region []S
+		for _, a := range m.sm.ancestors(s) {
+			if a == m.current {
+				break
+			}
+			region = append(region, a)
+		}
+		slices.Reverse(region)
+		states = append(states, region...)
+	}
+	return states
+}
+
+// schedule starts the timeouts of the given states; the caller must hold m.mu
+func (m *Machine[S, E]) schedule(states []S) {
+	for _, s := range states {
+		for _, t := range m.sm.timeouts[s] {
+			p := &pendingTimeout{}
+			p.timer = m.clock.AfterFunc(t.after, func() {
+				m.expire(s, p, t.event)
+			})
+			m.pending[s] = append(m.pending[s], p)
+		}
+	}
+}
+
+// cancel stops the timeouts of the given states; the caller must hold m.mu
+func (m *Machine[S, E]) cancel(states []S) {
+	for _, s := range states {
+		for _, p := range m.pending[s] {
+			p.timer.Stop()
+		}
+		delete(m.pending, s)
+	}
+}
+
+// expire fires the event of timeout p unless p was cancelled in the meantime.
+// If the event is rejected, the machine stays in its state.
+func (m *Machine[S, E]) expire(s S, p *pendingTimeout, event E) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.Index(m.pending[s], p)
+	if i < 0 {
+		return
+	}
+	m.pending[s] = slices.Delete(m.pending[s], i, i+1)
+	_ = m.fire(context.Background(), event, &dispatch{})
+}
@@ -0,0 +1,149 @@
+package zstate_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/upamune/zstate"
+)
+
+func TestMachine(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("undeclared initial state", func(t *testing.T) {
+		t.Parallel()
+		sm := buildDoorStateMachine(t)
+
+		_, err := zstate.NewMachine(sm, DoorState("Ajar"))
+		var stateErr *zstate.StateError[DoorState]
+		if !errors.As(err, &stateErr) {
+			t.Fatalf("Expected StateError, got %v", err)
+		}
+	})
+
+	t.Run("Fire", func(t *testing.T) {
+		t.Parallel()
+		sm := buildDoorStateMachine(t)
+
+		m, err := zstate.NewMachine(sm, Closed)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if m.Current() != Closed {
+			t.Errorf("Expected state Closed, got %v", m.Current())
+		}
+
+		for _, tt := range []struct {
+			event DoorEvent
+			want  DoorState
+		}{
+			{OpenDoor, Open},
+			{CloseDoor, Closed},
+			{LockDoor, Locked},
+			{UnlockDoor, Closed},
+		} {
+			if err := m.Fire(ctx, tt.event); err != nil {
+				t.Fatalf("Unexpected error firing %v: %v", tt.event, err)
+			}
+			if m.Current() != tt.want {
+				t.Errorf("Expected state %v after %v, got %v", tt.want, tt.event, m.Current())
+			}
+		}
+	})
+
+	t.Run("failed transition keeps state", func(t *testing.T) {
+		t.Parallel()
+		sm := buildDoorStateMachine(t)
+
+		m, err := zstate.NewMachine(sm, Closed)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		err = m.Fire(ctx, UnlockDoor)
+		var noTransitionErr *zstate.NoTransitionError[DoorState, DoorEvent]
+		if !errors.As(err, &noTransitionErr) {
+			t.Fatalf("Expected NoTransitionError, got %v", err)
+		}
+		if m.Current() != Closed {
+			t.Errorf("Expected state Closed, got %v", m.Current())
+		}
+	})
+}
+
+func TestMachineConcurrentFire(t *testing.T) {
+	t.Parallel()
+
+	var inFlight atomic.Int32
+	var overlapped atomic.Bool
+	var transitions int // deliberately unsynchronized, guarded by the machine
+	enter := func(ctx context.Context, from, to DoorState, event DoorEvent) bool {
+		if inFlight.Add(1) != 1 {
+			overlapped.Store(true)
+		}
+		return true
+	}
+	leave := func(ctx context.Context, from, to DoorState, event DoorEvent) {
+		transitions++
+		inFlight.Add(-1)
+	}
+
+	builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent]()
+	sm, err := builder.
+		AddState(Closed).
+		AddState(Open).
+		AddTransition(Closed, Open, OpenDoor,
+			zstate.WithGuard[DoorState, DoorEvent](enter),
+			zstate.WithAfter[DoorState, DoorEvent](leave),
+		).
+		AddTransition(Open, Closed, CloseDoor,
+			zstate.WithGuard[DoorState, DoorEvent](enter),
+			zstate.WithAfter[DoorState, DoorEvent](leave),
+		).
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build state machine: %v", err)
+	}
+
+	m, err := zstate.NewMachine(sm, Closed)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	const goroutines = 8
+	const events = 100
+	var succeeded atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(event DoorEvent) {
+			defer wg.Done()
+			for j := 0; j < events; j++ {
+				if err := m.Fire(context.Background(), event); err == nil {
+					succeeded.Add(1)
+				}
+				_ = m.Current()
+			}
+		}([]DoorEvent{OpenDoor, CloseDoor}[i%2])
+	}
+	wg.Wait()
+
+	if overlapped.Load() {
+		t.Error("Transitions were processed concurrently")
+	}
+	if int(succeeded.Load()) != transitions {
+		t.Errorf("Expected %d completed transitions, got %d", succeeded.Load(), transitions)
+	}
+	want := Closed
+	if transitions%2 == 1 {
+		want = Open
+	}
+	if m.Current() != want {
+		t.Errorf("Expected state %v after %d transitions, got %v", want, transitions, m.Current())
+	}
+}
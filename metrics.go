@@ -0,0 +1,42 @@
+package zstate
+
+import (
+	"time"
+)
+
+// Metrics receives the measurements of a state machine configured with WithMetrics.
+// States and events are formatted with fmt.Sprint. Implementations must be safe for
+// concurrent use. The promzstate package exposes them in the Prometheus exposition format.
+type Metrics interface {
+	// Transition counts a completed transition, including internal transitions
+	Transition(from, to, event string)
+	// GuardRejected counts an event rejected because the guards of every candidate transition failed
+	GuardRejected(from, event string)
+	// NoTransition counts an event without a transition from the current state
+	NoTransition(from, event string)
+	// CallbackDuration observes how long a guard, before callback or after callback took.
+	// kind is "guard", "before" or "after".
+	CallbackDuration(kind, event string, d time.Duration)
+}
+
+// WithMetrics makes the state machine report its transitions, rejected events
+// and the duration of its guards and callbacks to metrics
+func WithMetrics(metrics Metrics) BuilderOption {
+	return func(c *builderConfig) {
+		c.metrics = metrics
+	}
+}
+
+// timedSpan is a span that reports the duration of a guard or callback when it ends
+type timedSpan struct {
+	Span
+	metrics Metrics
+	kind    string
+	event   string
+	start   time.Time
+}
+
+func (s *timedSpan) End(err error) {
+	s.metrics.CallbackDuration(s.kind, s.event, time.Since(s.start))
+	s.Span.End(err)
+}
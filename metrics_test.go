@@ -0,0 +1,84 @@
+package zstate_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/upamune/zstate"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	metrics := &recordingMetrics{}
+	pass := func(ctx context.Context, from, to JobState, event JobEvent) bool {
+		return true
+	}
+	callback := func(ctx context.Context, from, to JobState, event JobEvent) {}
+
+	builder := zstate.NewStateMachineBuilder[JobState, JobEvent](zstate.WithMetrics(metrics))
+	sm, err := builder.
+		AddState(Queued).
+		AddState(Running).
+		AddState(Held).
+		AddTransition(Queued, Running, Start, zstate.WithGuard(pass), zstate.WithBefore(callback), zstate.WithAfter(callback)).
+		AddTransition(Running, Held, Hold, zstate.WithGuard(func(ctx context.Context, from, to JobState, event JobEvent) bool {
+			return false
+		})).
+		AddInternalTransition(Running, Requeue).
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build state machine: %v", err)
+	}
+
+	if _, err := sm.Trigger(ctx, Queued, Start); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := sm.Trigger(ctx, Running, Hold); err == nil {
+		t.Fatal("Expected guard error, got nil")
+	}
+	if _, err := sm.Trigger(ctx, Held, Complete); err == nil {
+		t.Fatal("Expected no transition error, got nil")
+	}
+	if _, err := sm.Trigger(ctx, Running, Requeue); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := []string{
+		"callback guard Start",
+		"callback before Start",
+		"callback after Start",
+		"transition Queued Running Start",
+		"callback guard Hold",
+		"guard rejected Running Hold",
+		"no transition Held Complete",
+		"transition Running Running Requeue",
+	}
+	if !slices.Equal(metrics.calls, want) {
+		t.Errorf("Expected calls %v, got %v", want, metrics.calls)
+	}
+}
+
+// recordingMetrics is a zstate.Metrics recording the measurements it receives
+type recordingMetrics struct {
+	calls []string
+}
+
+func (m *recordingMetrics) Transition(from, to, event string) {
+	m.calls = append(m.calls, "transition "+from+" "+to+" "+event)
+}
+
+func (m *recordingMetrics) GuardRejected(from, event string) {
+	m.calls = append(m.calls, "guard rejected "+from+" "+event)
+}
+
+func (m *recordingMetrics) NoTransition(from, event string) {
+	m.calls = append(m.calls, "no transition "+from+" "+event)
+}
+
+func (m *recordingMetrics) CallbackDuration(kind, event string, d time.Duration) {
+	m.calls = append(m.calls, "callback "+kind+" "+event)
+}
@@ -0,0 +1,71 @@
+// Package otelzstate records the spans of a zstate state machine with OpenTelemetry.
+//
+// Pass a Tracer to zstate.WithTracer:
+//
+//	builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent](
+//		zstate.WithTracer(otelzstate.NewTracer(otel.Tracer("door"))),
+//	)
+package otelzstate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/upamune/zstate"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer adapts an OpenTelemetry tracer to zstate.Tracer
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer creates a Tracer starting its spans with tracer
+func NewTracer(tracer trace.Tracer) *Tracer {
+	return &Tracer{tracer: tracer}
+}
+
+// Start starts an OpenTelemetry span as a child of the span carried by ctx
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...zstate.Attribute) (context.Context, zstate.Span) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithAttributes(convert(attrs)...))
+	return ctx, &Span{span: span}
+}
+
+// Span adapts an OpenTelemetry span to zstate.Span
+type Span struct {
+	span trace.Span
+}
+
+// SetAttributes adds attributes to the span
+func (s *Span) SetAttributes(attrs ...zstate.Attribute) {
+	s.span.SetAttributes(convert(attrs)...)
+}
+
+// End ends the span. A non-nil err is recorded on the span, whose status is set to Error.
+func (s *Span) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
+
+// convert converts zstate attributes to OpenTelemetry attributes
+func convert(attrs []zstate.Attribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		switch v := a.Value.(type) {
+		case string:
+			kvs = append(kvs, attribute.String(a.Key, v))
+		case int:
+			kvs = append(kvs, attribute.Int(a.Key, v))
+		case bool:
+			kvs = append(kvs, attribute.Bool(a.Key, v))
+		default:
+			kvs = append(kvs, attribute.String(a.Key, fmt.Sprint(v)))
+		}
+	}
+	return kvs
+}
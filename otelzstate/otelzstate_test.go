@@ -0,0 +1,112 @@
+package otelzstate_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/upamune/zstate"
+	"github.com/upamune/zstate/otelzstate"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type DoorState string
+
+const (
+	Closed DoorState = "Closed"
+	Open   DoorState = "Open"
+)
+
+type DoorEvent string
+
+const (
+	OpenDoor  DoorEvent = "OpenDoor"
+	CloseDoor DoorEvent = "CloseDoor"
+)
+
+func TestTracer(t *testing.T) {
+	t.Parallel()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := provider.Tracer("door")
+
+	errJammed := errors.New("door jammed")
+	builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent](zstate.WithTracer(otelzstate.NewTracer(tracer)))
+	sm, err := builder.
+		AddState(Closed).
+		AddState(Open).
+		AddTransition(Closed, Open, OpenDoor, zstate.WithGuard(func(ctx context.Context, from, to DoorState, event DoorEvent) bool {
+			return true
+		})).
+		AddTransition(Open, Closed, CloseDoor, zstate.WithBeforeE(func(ctx context.Context, from, to DoorState, event DoorEvent) error {
+			return errJammed
+		})).
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build state machine: %v", err)
+	}
+
+	ctx, parent := tracer.Start(context.Background(), "request")
+	if _, err := sm.Trigger(ctx, Closed, OpenDoor); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := sm.Trigger(ctx, Open, CloseDoor); err == nil {
+		t.Fatal("Expected transition error, got nil")
+	}
+	parent.End()
+
+	spans := recorder.Ended()
+	byName := make(map[string][]sdktrace.ReadOnlySpan)
+	for _, s := range spans {
+		byName[s.Name()] = append(byName[s.Name()], s)
+	}
+
+	triggers := byName["zstate.trigger"]
+	if len(triggers) != 2 {
+		t.Fatalf("Expected 2 trigger spans, got %d", len(triggers))
+	}
+	for _, s := range triggers {
+		if s.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("Expected trigger span to be a child of the request span")
+		}
+	}
+
+	opened := attributes(triggers[0])
+	for key, want := range map[string]string{"zstate.from": "Closed", "zstate.to": "Open", "zstate.event": "OpenDoor", "zstate.outcome": "transitioned"} {
+		if got := opened[attribute.Key(key)].AsString(); got != want {
+			t.Errorf("Expected %s %q, got %q", key, want, got)
+		}
+	}
+
+	guards := byName["zstate.guard"]
+	if len(guards) != 1 || guards[0].Parent().SpanID() != triggers[0].SpanContext().SpanID() {
+		t.Fatalf("Expected a guard span under the first trigger span, got %v", guards)
+	}
+	if allowed := attributes(guards[0])["zstate.allowed"]; !allowed.AsBool() {
+		t.Errorf("Expected zstate.allowed to be true")
+	}
+
+	befores := byName["zstate.before"]
+	if len(befores) != 1 || befores[0].Status().Code != codes.Error || befores[0].Status().Description != errJammed.Error() {
+		t.Fatalf("Expected a failed before span, got %v", befores)
+	}
+	if triggers[1].Status().Code != codes.Error {
+		t.Errorf("Expected the second trigger span to be failed, got %v", triggers[1].Status())
+	}
+	if outcome := attributes(triggers[1])["zstate.outcome"].AsString(); outcome != "failed" {
+		t.Errorf("Expected outcome failed, got %q", outcome)
+	}
+}
+
+// attributes returns the attributes of s by key
+func attributes(s sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range s.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
@@ -0,0 +1,271 @@
+package zstate
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+)
+
+// Configuration is the combined state of a parallel state: one active state per region,
+// in the order the regions were added
+type Configuration[S comparable] []S
+
+// AddRegion adds an orthogonal region to the parallel state parent.
+// The given states become children of parent, and the first of them is the initial state of the region.
+// States that were not added before are added without options.
+// Events are dispatched to the regions by a Machine in the parallel state and by TriggerParallel;
+// Trigger only sees the transitions of the state it is given.
+func (b *stateMachineBuilder[S, E]) AddRegion(parent S, states ...S) StateMachineBuilder[S, E] {
+	for _, s := range states {
+		b.AddState(s, WithParent[S, E](parent))
+	}
+	b.regions[parent] = append(b.regions[parent], states)
+	return b
+}
+
+// Regions returns the orthogonal regions of the parallel state parent
+func (sm *StateMachine[S, E]) Regions(parent S) [][]S {
+	return sm.regions[parent]
+}
+
+// InitialConfiguration returns the configuration of the parallel state parent
+// in which every region is in its initial state
+func (sm *StateMachine[S, E]) InitialConfiguration(parent S) (Configuration[S], error) {
+	regions := sm.regions[parent]
+	if len(regions) == 0 {
+		return nil, &StateError[S]{State: parent, Msg: "state has no regions"}
+	}
+
+	cfg := make(Configuration[S], 0, len(regions))
+	for _, region := range regions {
+		cfg = append(cfg, region[0])
+	}
+	return cfg, nil
+}
+
+// TriggerParallel dispatches event to the parallel state parent whose regions are in the
+// configuration cfg, and returns the state the event leads to together with its configuration.
+//
+// Each region advances independently using the transitions of its active state and their
+// ancestors inside the region; regions without a transition for the event keep their state.
+// The returned state is then parent and the new configuration is returned as a new value.
+// Transitions from any state are only considered by a region if they stay in it.
+//
+// A transition of a region that targets a state outside of the region exits the whole parallel
+// state: the active states of every region are exited, followed by parent. The transitions of
+// parent, of its ancestors and from any state are evaluated the same way when no region has a
+// transition for the event. The returned state is then the state entered; if it is a parallel
+// state, or a state in one of its regions, the parallel state and its configuration are returned.
+// Otherwise the returned configuration is nil.
+//
+// A NoTransitionError is returned if there is no transition for the event,
+// and the joined GuardErrors if every transition for it was rejected.
+// The before callbacks of every region run before any region exits its state, so if one of them
+// fails, no region has changed and parent and the original configuration are returned with the error.
+// Listeners are notified once every region has completed its transition.
+func (sm *StateMachine[S, E]) TriggerParallel(ctx context.Context, parent S, cfg Configuration[S], event E) (S, Configuration[S], error) {
+	r, err := sm.triggerParallel(ctx, parent, cfg, event, &dispatch{}, nil)
+	return r.state, r.cfg, err
+}
+
+// parallelStep is the outcome of an event dispatched to a parallel state
+type parallelStep[S comparable] struct {
+	state S
+	cfg   Configuration[S]
+	// exit and enter are the states exited and entered, in the order their actions ran
+	exit, enter []S
+}
+
+// triggerParallel implements TriggerParallel for an event accompanied by d.
+// If history is not nil, history transitions are resolved against it and it is updated in place.
+func (sm *StateMachine[S, E]) triggerParallel(ctx context.Context, parent S, cfg Configuration[S], event E, d *dispatch, history History[S]) (parallelStep[S], error) {
+	unchanged := parallelStep[S]{state: parent, cfg: cfg}
+	regions := sm.regions[parent]
+	if len(regions) == 0 {
+		return unchanged, &StateError[S]{State: parent, Msg: "state has no regions"}
+	}
+	if len(cfg) != len(regions) {
+		return unchanged, &StateError[S]{State: parent, Msg: "configuration does not match the regions of the state"}
+	}
+
+	start := time.Now()
+	ctx, span := sm.startTrigger(ctx, parent, event)
+
+	// Resolve every region before running any action so that guards see the original configuration
+	selected := make([]*transition[S, E], len(cfg))
+	targets := make([]S, len(cfg))
+	leaving := -1
+	var rejected []error
+	for i, current := range cfg {
+		t, to, err := sm.resolve(ctx, current, event, d, &parent, history)
+		var guardErr *GuardError[S, E]
+		switch {
+		case err == nil:
+			selected[i] = t
+			targets[i] = to
+			if leaving < 0 && sm.regionOf(parent, to) != i {
+				leaving = i
+			}
+		case errors.As(err, &guardErr):
+			rejected = append(rejected, err)
+		}
+	}
+
+	if leaving >= 0 {
+		return sm.leaveParallel(ctx, span, start, parent, cfg, cfg[leaving], selected[leaving], targets[leaving], event, d, history)
+	}
+
+	if slices.ContainsFunc(selected, func(t *transition[S, E]) bool { return t != nil }) {
+		// Every region is prepared before any of them commits, so that a failing before
+		// callback aborts the event in all regions
+		for i, t := range selected {
+			if t == nil {
+				continue
+			}
+			if err := sm.prepare(ctx, cfg[i], t, targets[i], event, d); err != nil {
+				sm.logAttempt(ctx, cfg[i], event, start, cfg[i], err)
+				sm.endTrigger(span, parent, err)
+				return unchanged, err
+			}
+		}
+
+		next := parallelStep[S]{state: parent, cfg: slices.Clone(cfg)}
+		for i, t := range selected {
+			if t == nil {
+				continue
+			}
+			exit, enter := sm.steps(cfg[i], t, targets[i])
+			next.cfg[i] = sm.commit(ctx, cfg[i], t, targets[i], exit, enter, event, d, history)
+			next.exit = append(next.exit, exit...)
+			next.enter = append(next.enter, enter...)
+		}
+		for i, t := range selected {
+			if t == nil {
+				continue
+			}
+			sm.logAttempt(ctx, cfg[i], event, start, next.cfg[i], nil)
+			sm.notifyTransition(ctx, cfg[i], next.cfg[i], event)
+		}
+		sm.endTrigger(span, parent, nil)
+		return next, nil
+	}
+
+	// No region handles the event, so the transitions of the parallel state itself apply
+	t, to, err := sm.resolve(ctx, parent, event, d, nil, history)
+	if err == nil {
+		return sm.leaveParallel(ctx, span, start, parent, cfg, parent, t, to, event, d, history)
+	}
+	var guardErr *GuardError[S, E]
+	if errors.As(err, &guardErr) {
+		rejected = append(rejected, err)
+	}
+	if len(rejected) > 0 {
+		err = errors.Join(rejected...)
+		for _, err := range rejected {
+			sm.notifyRejected(ctx, err)
+		}
+	} else {
+		sm.notifyRejected(ctx, err)
+	}
+	sm.endTrigger(span, parent, err)
+	sm.logAttempt(ctx, parent, event, start, parent, err)
+	return unchanged, err
+}
+
+// leaveParallel takes transition t from the state from, which is parent or one of the states
+// of its configuration cfg, to a state outside of the region of from. Every region and parent
+// itself are exited, unless t is an internal transition of parent or one of its ancestors.
+func (sm *StateMachine[S, E]) leaveParallel(ctx context.Context, span Span, start time.Time, parent S, cfg Configuration[S], from S, t *transition[S, E], to S, event E, d *dispatch, history History[S]) (parallelStep[S], error) {
+	var exit, enter []S
+	if !t.internal {
+		// Regions are exited in reverse order, each from its active state outwards
+		for i := len(cfg) - 1; i >= 0; i-- {
+			for _, s := range sm.ancestors(cfg[i]) {
+				if s == parent {
+					break
+				}
+				exit = append(exit, s)
+			}
+		}
+		source := t.from
+		if sm.regionOf(parent, source) >= 0 {
+			source = parent
+		}
+		outer, inner := sm.path(parent, source, to)
+		exit = append(exit, outer...)
+		enter = sm.complete(inner)
+	}
+
+	next, err := sm.execute(ctx, from, t, to, exit, enter, event, d, history)
+	sm.logAttempt(ctx, from, event, start, next, err)
+	if err != nil {
+		sm.endTrigger(span, parent, err)
+		return parallelStep[S]{state: parent, cfg: cfg}, err
+	}
+	sm.notifyTransition(ctx, from, next, event)
+
+	step := parallelStep[S]{state: parent, cfg: slices.Clone(cfg), exit: exit, enter: enter}
+	if !t.internal {
+		step.state, step.cfg = sm.settle(next)
+	}
+	sm.endTrigger(span, step.state, nil)
+	return step, nil
+}
+
+// settle returns the state a machine is in once s has been entered, with its configuration:
+// the innermost parallel state containing s, or s itself, with the initial configuration of
+// its regions except for the region of s. States outside of every region have no configuration.
+func (sm *StateMachine[S, E]) settle(s S) (S, Configuration[S]) {
+	for _, a := range sm.ancestors(s) {
+		if len(sm.regions[a]) == 0 {
+			continue
+		}
+		cfg, _ := sm.InitialConfiguration(a)
+		if i := sm.regionOf(a, s); i >= 0 {
+			cfg[i] = s
+		}
+		return a, cfg
+	}
+	return s, nil
+}
+
+// complete adds to the states entered, outermost first, the initial states of the regions of
+// every parallel state entered that the path does not lead into
+func (sm *StateMachine[S, E]) complete(enter []S) []S {
+	for i, s := range enter {
+		regions := sm.regions[s]
+		if len(regions) == 0 {
+			continue
+		}
+		rest := enter[i+1:]
+		into := -1
+		if len(rest) > 0 {
+			into = sm.regionOf(s, rest[0])
+		}
+		completed := slices.Clone(enter[:i+1])
+		for r, region := range regions {
+			if r == into {
+				completed = append(completed, sm.complete(rest)...)
+			} else {
+				completed = append(completed, sm.complete([]S{region[0]})...)
+			}
+		}
+		return completed
+	}
+	return enter
+}
+
+// regionOf returns the index of the region of parent that contains s, or -1 if there is none
+func (sm *StateMachine[S, E]) regionOf(parent, s S) int {
+	for _, a := range sm.ancestors(s) {
+		for i, region := range sm.regions[parent] {
+			for _, r := range region {
+				if r == a {
+					return i
+				}
+			}
+		}
+	}
+	return -1
+}
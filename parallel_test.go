@@ -0,0 +1,330 @@
+package zstate_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/upamune/zstate"
+)
+
+type DeviceState string
+
+const (
+	Device  DeviceState = "Device"
+	Off     DeviceState = "Off"
+	On      DeviceState = "On"
+	Offline DeviceState = "Offline"
+	Online  DeviceState = "Online"
+	Dead    DeviceState = "Dead"
+)
+
+type DeviceEvent string
+
+const (
+	PowerOn    DeviceEvent = "PowerOn"
+	Connect    DeviceEvent = "Connect"
+	Reset      DeviceEvent = "Reset"
+	Interfere  DeviceEvent = "Interfere"
+	SelfDetect DeviceEvent = "SelfDetect"
+	Break      DeviceEvent = "Break"
+	Shutdown   DeviceEvent = "Shutdown"
+)
+
+func TestParallelRegions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("regions advance independently", func(t *testing.T) {
+		t.Parallel()
+		sm := buildDeviceStateMachine(t)
+
+		cfg, err := sm.InitialConfiguration(Device)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if want := (zstate.Configuration[DeviceState]{Off, Offline}); !slices.Equal(cfg, want) {
+			t.Fatalf("Expected initial configuration %v, got %v", want, cfg)
+		}
+
+		for _, tt := range []struct {
+			event DeviceEvent
+			want  zstate.Configuration[DeviceState]
+		}{
+			{PowerOn, zstate.Configuration[DeviceState]{On, Offline}},
+			{Connect, zstate.Configuration[DeviceState]{On, Online}},
+			{Reset, zstate.Configuration[DeviceState]{Off, Offline}},
+		} {
+			state, next, err := sm.TriggerParallel(ctx, Device, cfg, tt.event)
+			if err != nil {
+				t.Fatalf("Unexpected error for %v: %v", tt.event, err)
+			}
+			if state != Device || !slices.Equal(next, tt.want) {
+				t.Errorf("Expected Device with configuration %v after %v, got %v with %v", tt.want, tt.event, state, next)
+			}
+			cfg = next
+		}
+	})
+
+	t.Run("configuration is a value", func(t *testing.T) {
+		t.Parallel()
+		sm := buildDeviceStateMachine(t)
+
+		cfg := zstate.Configuration[DeviceState]{Off, Offline}
+		_, next, err := sm.TriggerParallel(ctx, Device, cfg, PowerOn)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if cfg[0] != Off {
+			t.Errorf("Expected original configuration to be unchanged, got %v", cfg)
+		}
+		if next[0] != On {
+			t.Errorf("Expected new configuration to be On, got %v", next)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+		sm := buildDeviceStateMachine(t)
+		cfg := zstate.Configuration[DeviceState]{Off, Offline}
+
+		_, _, err := sm.TriggerParallel(ctx, Device, cfg, Shutdown)
+		var noTransitionErr *zstate.NoTransitionError[DeviceState, DeviceEvent]
+		if !errors.As(err, &noTransitionErr) {
+			t.Errorf("Expected NoTransitionError, got %v", err)
+		}
+
+		_, _, err = sm.TriggerParallel(ctx, Device, cfg, Interfere)
+		var guardErr *zstate.GuardError[DeviceState, DeviceEvent]
+		if !errors.As(err, &guardErr) {
+			t.Errorf("Expected GuardError, got %v", err)
+		}
+
+		_, _, err = sm.TriggerParallel(ctx, Device, zstate.Configuration[DeviceState]{Off}, PowerOn)
+		var stateErr *zstate.StateError[DeviceState]
+		if !errors.As(err, &stateErr) {
+			t.Errorf("Expected StateError, got %v", err)
+		}
+	})
+
+	t.Run("leaving the parallel state", func(t *testing.T) {
+		t.Parallel()
+		var calls []string
+		record := func(name string) zstate.TransitionCallback[DeviceState, DeviceEvent] {
+			return func(ctx context.Context, from, to DeviceState, event DeviceEvent) {
+				calls = append(calls, name)
+			}
+		}
+		builder := zstate.NewStateMachineBuilder[DeviceState, DeviceEvent](zstate.WithStrictValidation())
+		sm, err := builder.
+			AddState(Device, zstate.OnEnter(record("enter Device")), zstate.OnExit(record("exit Device"))).
+			AddState(Off, zstate.OnEnter(record("enter Off")), zstate.OnExit(record("exit Off"))).
+			AddState(On, zstate.OnEnter(record("enter On")), zstate.OnExit(record("exit On"))).
+			AddState(Offline, zstate.OnEnter(record("enter Offline")), zstate.OnExit(record("exit Offline"))).
+			AddState(Online, zstate.OnEnter(record("enter Online")), zstate.OnExit(record("exit Online"))).
+			AddFinalState(Dead).
+			SetInitial(Device).
+			AddRegion(Device, Off, On).
+			AddRegion(Device, Offline, Online).
+			AddTransition(Off, On, PowerOn).
+			AddTransition(On, Off, Reset).
+			AddTransition(Offline, Online, Connect).
+			AddTransition(Online, Offline, Reset).
+			AddTransition(On, Online, SelfDetect).
+			AddTransition(Device, Dead, Break).
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+
+		// A transition of the parallel state itself exits every region and the parallel state
+		state, cfg, err := sm.TriggerParallel(ctx, Device, zstate.Configuration[DeviceState]{On, Online}, Break)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if state != Dead || cfg != nil {
+			t.Errorf("Expected Dead without configuration, got %v with %v", state, cfg)
+		}
+		if want := []string{"exit Online", "exit On", "exit Device"}; !slices.Equal(calls, want) {
+			t.Errorf("Expected calls %v, got %v", want, calls)
+		}
+
+		// A transition into another region exits and re-enters the parallel state
+		calls = nil
+		state, cfg, err = sm.TriggerParallel(ctx, Device, zstate.Configuration[DeviceState]{On, Offline}, SelfDetect)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if want := (zstate.Configuration[DeviceState]{Off, Online}); state != Device || !slices.Equal(cfg, want) {
+			t.Errorf("Expected Device with configuration %v, got %v with %v", want, state, cfg)
+		}
+		want := []string{"exit Offline", "exit On", "exit Device", "enter Device", "enter Off", "enter Online"}
+		if !slices.Equal(calls, want) {
+			t.Errorf("Expected calls %v, got %v", want, calls)
+		}
+	})
+
+	t.Run("transitions from any state", func(t *testing.T) {
+		t.Parallel()
+		builder := zstate.NewStateMachineBuilder[DeviceState, DeviceEvent](zstate.WithStrictValidation())
+		sm, err := builder.
+			AddState(Device).
+			AddFinalState(Dead).
+			SetInitial(Device).
+			AddRegion(Device, Off, On).
+			AddRegion(Device, Offline, Online).
+			AddTransition(Off, On, PowerOn).
+			AddTransition(On, Off, Reset).
+			AddTransition(Offline, Online, Shutdown).
+			AddTransition(Online, Offline, Reset).
+			AddTransitionFromAny(Dead, Shutdown).
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+
+		// The wildcard leaves the regions, so only the transition of the second region fires
+		state, cfg, err := sm.TriggerParallel(ctx, Device, zstate.Configuration[DeviceState]{Off, Offline}, Shutdown)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if want := (zstate.Configuration[DeviceState]{Off, Online}); state != Device || !slices.Equal(cfg, want) {
+			t.Errorf("Expected Device with configuration %v, got %v with %v", want, state, cfg)
+		}
+
+		// Without a region transition, the wildcard exits the parallel state
+		state, cfg, err = sm.TriggerParallel(ctx, Device, cfg, Shutdown)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if state != Dead || cfg != nil {
+			t.Errorf("Expected Dead without configuration, got %v with %v", state, cfg)
+		}
+	})
+
+	t.Run("failing before callback", func(t *testing.T) {
+		t.Parallel()
+		var calls []string
+		record := func(name string) zstate.TransitionCallback[DeviceState, DeviceEvent] {
+			return func(ctx context.Context, from, to DeviceState, event DeviceEvent) {
+				calls = append(calls, name)
+			}
+		}
+		errUnreachable := errors.New("network unreachable")
+
+		builder := zstate.NewStateMachineBuilder[DeviceState, DeviceEvent]()
+		sm, err := builder.
+			AddState(Device).
+			AddState(Off, zstate.OnExit(record("exit Off"))).
+			AddRegion(Device, Off, On).
+			AddRegion(Device, Offline, Online).
+			AddTransition(Off, On, PowerOn, zstate.WithAfter(record("after Off -> On"))).
+			AddTransition(Offline, Online, PowerOn, zstate.WithBeforeE(func(ctx context.Context, from, to DeviceState, event DeviceEvent) error {
+				return errUnreachable
+			})).
+			OnTransition(func(ctx context.Context, from, to DeviceState, event DeviceEvent) {
+				calls = append(calls, "transition "+string(from)+" -> "+string(to))
+			}).
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+
+		cfg := zstate.Configuration[DeviceState]{Off, Offline}
+		state, got, err := sm.TriggerParallel(ctx, Device, cfg, PowerOn)
+		if !errors.Is(err, errUnreachable) {
+			t.Fatalf("Expected the before callback error, got %v", err)
+		}
+		if state != Device || !slices.Equal(got, cfg) {
+			t.Errorf("Expected Device with configuration %v, got %v with %v", cfg, state, got)
+		}
+		if len(calls) > 0 {
+			t.Errorf("Expected no region to transition, got calls %v", calls)
+		}
+	})
+
+	t.Run("machine", func(t *testing.T) {
+		t.Parallel()
+		sm := buildDeviceStateMachine(t)
+		m, err := zstate.NewMachine(sm, Device)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		for _, tt := range []struct {
+			event DeviceEvent
+			state DeviceState
+			cfg   zstate.Configuration[DeviceState]
+		}{
+			{PowerOn, Device, zstate.Configuration[DeviceState]{On, Offline}},
+			{Connect, Device, zstate.Configuration[DeviceState]{On, Online}},
+			{Break, Dead, nil},
+		} {
+			if err := m.Fire(ctx, tt.event); err != nil {
+				t.Fatalf("Unexpected error for %v: %v", tt.event, err)
+			}
+			if m.Current() != tt.state || !slices.Equal(m.Configuration(), tt.cfg) {
+				t.Errorf("Expected %v with configuration %v after %v, got %v with %v", tt.state, tt.cfg, tt.event, m.Current(), m.Configuration())
+			}
+		}
+
+		if err := m.RestoreConfiguration(Device, zstate.Configuration[DeviceState]{On, Offline}, nil); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := m.Fire(ctx, Reset); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if want := (zstate.Configuration[DeviceState]{Off, Offline}); m.Current() != Device || !slices.Equal(m.Configuration(), want) {
+			t.Errorf("Expected Device with configuration %v, got %v with %v", want, m.Current(), m.Configuration())
+		}
+
+		var stateErr *zstate.StateError[DeviceState]
+		if err := m.RestoreConfiguration(Device, zstate.Configuration[DeviceState]{Online, Off}, nil); !errors.As(err, &stateErr) {
+			t.Errorf("Expected StateError, got %v", err)
+		}
+	})
+
+	t.Run("state in more than one region", func(t *testing.T) {
+		t.Parallel()
+		builder := zstate.NewStateMachineBuilder[DeviceState, DeviceEvent]()
+		_, err := builder.
+			AddState(Device).
+			AddRegion(Device, Off, On).
+			AddRegion(Device, On, Online).
+			Build()
+		var stateErr *zstate.StateError[DeviceState]
+		if !errors.As(err, &stateErr) || stateErr.State != On {
+			t.Fatalf("Expected StateError for On, got %v", err)
+		}
+	})
+}
+
+func buildDeviceStateMachine(t *testing.T) *zstate.StateMachine[DeviceState, DeviceEvent] {
+	t.Helper()
+
+	reject := zstate.WithGuard[DeviceState, DeviceEvent](func(ctx context.Context, from, to DeviceState, event DeviceEvent) bool {
+		return false
+	})
+
+	builder := zstate.NewStateMachineBuilder[DeviceState, DeviceEvent](zstate.WithStrictValidation())
+	sm, err := builder.
+		AddState(Device).
+		AddFinalState(Dead).
+		SetInitial(Device).
+		AddRegion(Device, Off, On).
+		AddRegion(Device, Offline, Online).
+		AddTransition(Device, Dead, Break).
+		AddTransition(Off, On, PowerOn).
+		AddTransition(On, Off, Reset).
+		AddTransition(Offline, Online, Connect).
+		AddTransition(Online, Offline, Reset).
+		AddTransition(Offline, Online, Interfere, reject).
+		Build()
+
+	if err != nil {
+		t.Fatalf("Failed to build state machine: %v", err)
+	}
+	return sm
+}
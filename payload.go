@@ -0,0 +1,76 @@
+package zstate
+
+import (
+	"context"
+	"reflect"
+)
+
+// PayloadGuard is a guard that also receives the payload of the event
+type PayloadGuard[S, E comparable, P any] func(ctx context.Context, from, to S, event E, payload P) bool
+
+// PayloadCallback is a transition callback that also receives the payload of the event
+type PayloadCallback[S, E comparable, P any] func(ctx context.Context, from, to S, event E, payload P)
+
+// WithPayloadGuard adds a guard function receiving the payload of the event to a transition.
+// The transition is rejected if the payload is not of type P.
+func WithPayloadGuard[S, E comparable, P any](guard PayloadGuard[S, E, P]) TransitionOption[S, E] {
+	return func(t *transition[S, E]) {
+		t.accepts = append(t.accepts, acceptPayload[P])
+		t.guards = append(t.guards, func(ctx context.Context, from, to S, event E, d *dispatch) error {
+			if !guard(ctx, from, to, event, payloadAs[P](d.payload)) {
+				return errGuardRejected
+			}
+			return nil
+		})
+	}
+}
+
+// WithPayloadBefore adds a before callback receiving the payload of the event to a transition.
+// The transition is rejected if the payload is not of type P.
+func WithPayloadBefore[S, E comparable, P any](callback PayloadCallback[S, E, P]) TransitionOption[S, E] {
+	return func(t *transition[S, E]) {
+		t.accepts = append(t.accepts, acceptPayload[P])
+		t.befores = append(t.befores, func(ctx context.Context, from, to S, event E, d *dispatch) error {
+			callback(ctx, from, to, event, payloadAs[P](d.payload))
+			return nil
+		})
+	}
+}
+
+// WithPayloadAfter adds an after callback receiving the payload of the event to a transition.
+// The transition is rejected if the payload is not of type P.
+func WithPayloadAfter[S, E comparable, P any](callback PayloadCallback[S, E, P]) TransitionOption[S, E] {
+	return func(t *transition[S, E]) {
+		t.accepts = append(t.accepts, acceptPayload[P])
+		t.afters = append(t.afters, func(ctx context.Context, from, to S, event E, d *dispatch) {
+			callback(ctx, from, to, event, payloadAs[P](d.payload))
+		})
+	}
+}
+
+// acceptPayload reports a PayloadError unless the payload is nil or of type P
+func acceptPayload[P any](d *dispatch) error {
+	if d.payload == nil {
+		return nil
+	}
+	if _, ok := d.payload.(P); !ok {
+		return &PayloadError{Payload: d.payload, Want: reflect.TypeFor[P]().String()}
+	}
+	return nil
+}
+
+// payloadAs returns payload as a P, or the zero value of P if the event has no payload
+func payloadAs[P any](payload any) P {
+	p, _ := payload.(P)
+	return p
+}
+
+// TriggerWith works like Trigger for an event carrying a payload.
+// The payload is passed to the guards and callbacks added with WithPayloadGuard,
+// WithPayloadBefore and WithPayloadAfter; candidate transitions expecting a payload of
+// another type are rejected. Trigger is equivalent to TriggerWith with a nil payload,
+// for which payload-aware guards and callbacks receive the zero value.
+func (sm *StateMachine[S, E]) TriggerWith(ctx context.Context, currentState S, event E, payload any) (S, error) {
+	next, _, err := sm.trigger(ctx, currentState, event, &dispatch{payload: payload}, nil)
+	return next, err
+}
@@ -0,0 +1,150 @@
+package zstate_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/upamune/zstate"
+)
+
+func TestPayload(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("payload guard", func(t *testing.T) {
+		t.Parallel()
+		sm := buildPinDoorStateMachine(t, nil)
+
+		got, err := sm.TriggerWith(ctx, Locked, UnlockDoor, "1234")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got != Closed {
+			t.Errorf("Expected state Closed, got %v", got)
+		}
+
+		for _, payload := range []any{"0000", nil} {
+			got, err := sm.TriggerWith(ctx, Locked, UnlockDoor, payload)
+			var guardErr *zstate.GuardError[DoorState, DoorEvent]
+			if !errors.As(err, &guardErr) {
+				t.Fatalf("Expected GuardError for %v, got %v", payload, err)
+			}
+			if got != Locked {
+				t.Errorf("Expected state Locked for %v, got %v", payload, got)
+			}
+		}
+
+		if _, err := sm.Trigger(ctx, Locked, UnlockDoor); err == nil {
+			t.Error("Expected Trigger without payload to be rejected")
+		}
+	})
+
+	t.Run("payload of the wrong type", func(t *testing.T) {
+		t.Parallel()
+		sm := buildPinDoorStateMachine(t, nil)
+
+		_, err := sm.TriggerWith(ctx, Locked, UnlockDoor, 1234)
+		var guardErr *zstate.GuardError[DoorState, DoorEvent]
+		if !errors.As(err, &guardErr) || guardErr.Index != -1 {
+			t.Fatalf("Expected GuardError with index -1, got %v", err)
+		}
+		var payloadErr *zstate.PayloadError
+		if !errors.As(err, &payloadErr) || payloadErr.Want != "string" {
+			t.Fatalf("Expected PayloadError wanting string, got %v", err)
+		}
+	})
+
+	t.Run("payload callbacks", func(t *testing.T) {
+		t.Parallel()
+		var calls []string
+		sm := buildPinDoorStateMachine(t, &calls)
+
+		if _, err := sm.TriggerWith(ctx, Locked, UnlockDoor, "1234"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		want := []string{"before 1234", "after 1234"}
+		if !slices.Equal(calls, want) {
+			t.Errorf("Expected calls %v, got %v", want, calls)
+		}
+	})
+
+	t.Run("branching on payload type", func(t *testing.T) {
+		t.Parallel()
+		type override struct{ reason string }
+		builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent]()
+		sm, err := builder.
+			AddState(Locked).
+			AddState(Closed).
+			AddState(Broken).
+			AddTransition(Locked, Closed, UnlockDoor, zstate.WithPayloadGuard(func(ctx context.Context, from, to DoorState, event DoorEvent, pin string) bool {
+				return pin == "1234"
+			})).
+			AddTransition(Locked, Broken, UnlockDoor, zstate.WithPayloadGuard(func(ctx context.Context, from, to DoorState, event DoorEvent, o override) bool {
+				return o.reason != ""
+			})).
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+
+		got, err := sm.TriggerWith(ctx, Locked, UnlockDoor, override{reason: "fire"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got != Broken {
+			t.Errorf("Expected state Broken, got %v", got)
+		}
+	})
+
+	t.Run("machine", func(t *testing.T) {
+		t.Parallel()
+		m, err := zstate.NewMachine(buildPinDoorStateMachine(t, nil), Locked)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if err := m.Fire(ctx, UnlockDoor); err == nil {
+			t.Error("Expected Fire without payload to be rejected")
+		}
+		if err := m.FireWith(ctx, UnlockDoor, "1234"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if m.Current() != Closed {
+			t.Errorf("Expected state Closed, got %v", m.Current())
+		}
+	})
+}
+
+func buildPinDoorStateMachine(t *testing.T, calls *[]string) *zstate.StateMachine[DoorState, DoorEvent] {
+	t.Helper()
+
+	record := func(name string) zstate.PayloadCallback[DoorState, DoorEvent, string] {
+		return func(ctx context.Context, from, to DoorState, event DoorEvent, pin string) {
+			if calls != nil {
+				*calls = append(*calls, name+" "+pin)
+			}
+		}
+	}
+
+	builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent]()
+	sm, err := builder.
+		AddState(Closed).
+		AddState(Locked).
+		AddTransition(Closed, Locked, LockDoor).
+		AddTransition(Locked, Closed, UnlockDoor,
+			zstate.WithPayloadGuard(func(ctx context.Context, from, to DoorState, event DoorEvent, pin string) bool {
+				return pin == "1234"
+			}),
+			zstate.WithPayloadBefore(record("before")),
+			zstate.WithPayloadAfter(record("after")),
+		).
+		Build()
+
+	if err != nil {
+		t.Fatalf("Failed to build state machine: %v", err)
+	}
+	return sm
+}
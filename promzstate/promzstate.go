@@ -0,0 +1,224 @@
+// Package promzstate collects the metrics of zstate state machines and exposes them
+// in the Prometheus text exposition format, using only the standard library.
+//
+// A Collector is passed to zstate.WithMetrics and served over HTTP:
+//
+//	collector := promzstate.NewCollector()
+//	builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent](zstate.WithMetrics(collector))
+//	http.Handle("/metrics", collector)
+package promzstate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/upamune/zstate"
+)
+
+var _ zstate.Metrics = (*Collector)(nil)
+
+// DefaultBuckets are the upper bounds in seconds of the callback duration histogram unless set with WithBuckets
+var DefaultBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+// Collector is a zstate.Metrics exposing the metrics it receives in the Prometheus text
+// exposition format. It serves them over HTTP as an http.Handler and is safe for concurrent use.
+//
+// The following metrics are exposed, prefixed with the namespace:
+//
+//   - transitions_total{from, to, event}: completed transitions
+//   - guard_rejections_total{from, event}: events rejected by guards
+//   - no_transitions_total{from, event}: events without a transition
+//   - callback_duration_seconds{kind, event}: histogram of guard and callback durations
+type Collector struct {
+	namespace string
+	buckets   []float64
+
+	// The series are keyed by their formatted labels
+	mu              sync.Mutex
+	transitions     map[string]uint64
+	guardRejections map[string]uint64
+	noTransitions   map[string]uint64
+	callbacks       map[string]*histogram
+}
+
+// histogram holds the observations of a single callback duration series
+type histogram struct {
+	// counts holds the number of observations per bucket, not cumulated
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Option is a function type for configuring a Collector
+type Option func(*Collector)
+
+// WithNamespace sets the prefix of the metric names. The default is "zstate".
+// Use a different namespace for every state machine served by the same endpoint.
+func WithNamespace(namespace string) Option {
+	return func(c *Collector) {
+		c.namespace = namespace
+	}
+}
+
+// WithBuckets sets the upper bounds in seconds of the callback duration histogram, in increasing order
+func WithBuckets(buckets ...float64) Option {
+	return func(c *Collector) {
+		c.buckets = buckets
+	}
+}
+
+// NewCollector creates a new Collector
+func NewCollector(opts ...Option) *Collector {
+	c := &Collector{
+		namespace:       "zstate",
+		buckets:         DefaultBuckets,
+		transitions:     make(map[string]uint64),
+		guardRejections: make(map[string]uint64),
+		noTransitions:   make(map[string]uint64),
+		callbacks:       make(map[string]*histogram),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Transition counts a completed transition
+func (c *Collector) Transition(from, to, event string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.transitions[formatLabels("from", from, "to", to, "event", event)]++
+}
+
+// GuardRejected counts an event rejected by guards
+func (c *Collector) GuardRejected(from, event string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.guardRejections[formatLabels("from", from, "event", event)]++
+}
+
+// NoTransition counts an event without a transition
+func (c *Collector) NoTransition(from, event string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.noTransitions[formatLabels("from", from, "event", event)]++
+}
+
+// CallbackDuration observes the duration of a guard or callback
+func (c *Collector) CallbackDuration(kind, event string, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := formatLabels("kind", kind, "event", event)
+	h, ok := c.callbacks[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(c.buckets))}
+		c.callbacks[key] = h
+	}
+	v := d.Seconds()
+	if i, _ := slices.BinarySearch(c.buckets, v); i < len(c.buckets) {
+		h.counts[i]++
+	}
+	h.sum += v
+	h.count++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = c.WriteTo(w)
+}
+
+// WriteTo writes the metrics to w in the Prometheus text exposition format.
+// Series are sorted by their labels so that the output is stable.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	c.writeCounter(cw, "transitions_total", "Completed transitions.", c.transitions)
+	c.writeCounter(cw, "guard_rejections_total", "Events rejected because the guards of every candidate transition failed.", c.guardRejections)
+	c.writeCounter(cw, "no_transitions_total", "Events without a transition from the current state.", c.noTransitions)
+	c.writeHistogram(cw)
+	if err := cw.w.Flush(); err != nil && cw.err == nil {
+		cw.err = err
+	}
+	return cw.n, cw.err
+}
+
+func (c *Collector) writeCounter(w *countingWriter, name, help string, series map[string]uint64) {
+	name = c.namespace + "_" + name
+	w.printf("# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	for _, labels := range sortedKeys(series) {
+		w.printf("%s{%s} %d\n", name, labels, series[labels])
+	}
+}
+
+func (c *Collector) writeHistogram(w *countingWriter) {
+	name := c.namespace + "_callback_duration_seconds"
+	w.printf("# HELP %s Duration of guards, before callbacks and after callbacks.\n# TYPE %s histogram\n", name, name)
+	for _, labels := range sortedKeys(c.callbacks) {
+		h := c.callbacks[labels]
+		var cumulative uint64
+		for i, bound := range c.buckets {
+			cumulative += h.counts[i]
+			w.printf("%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(bound), cumulative)
+		}
+		w.printf("%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+		w.printf("%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+		w.printf("%s_count{%s} %d\n", name, labels, h.count)
+	}
+}
+
+// sortedKeys returns the keys of m in increasing order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// formatLabels formats alternating label names and values, escaping the values as required by the exposition format
+func formatLabels(namesAndValues ...string) string {
+	pairs := make([]string, 0, len(namesAndValues)/2)
+	for i := 0; i+1 < len(namesAndValues); i += 2 {
+		pairs = append(pairs, namesAndValues[i]+`="`+labelEscaper.Replace(namesAndValues[i+1])+`"`)
+	}
+	return strings.Join(pairs, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatFloat formats v as a sample value or bucket bound
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// countingWriter counts the bytes written and keeps the first error
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) printf(format string, args ...any) {
+	if w.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(w.w, format, args...)
+	w.n += int64(n)
+	w.err = err
+}
@@ -0,0 +1,140 @@
+package promzstate_test
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/upamune/zstate"
+	"github.com/upamune/zstate/promzstate"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+type DoorState string
+
+const (
+	Closed DoorState = "Closed"
+	Open   DoorState = "Open"
+	Locked DoorState = "Locked"
+)
+
+type DoorEvent string
+
+const (
+	OpenDoor   DoorEvent = "OpenDoor"
+	CloseDoor  DoorEvent = "CloseDoor"
+	LockDoor   DoorEvent = "LockDoor"
+	UnlockDoor DoorEvent = "UnlockDoor"
+)
+
+func TestCollector(t *testing.T) {
+	t.Parallel()
+
+	t.Run("exposition", func(t *testing.T) {
+		t.Parallel()
+		collector := promzstate.NewCollector(promzstate.WithNamespace("door"), promzstate.WithBuckets(0.001, 0.01, 0.1))
+		collector.Transition("Closed", "Open", "OpenDoor")
+		collector.Transition("Closed", "Open", "OpenDoor")
+		collector.Transition("Open", "Closed", "CloseDoor")
+		collector.GuardRejected("Closed", "LockDoor")
+		collector.NoTransition("Open", `Lock "now"`)
+		collector.CallbackDuration("guard", "LockDoor", 500*time.Microsecond)
+		collector.CallbackDuration("guard", "LockDoor", 5*time.Millisecond)
+		collector.CallbackDuration("before", "OpenDoor", 250*time.Millisecond)
+
+		body := scrape(t, collector)
+		assertGolden(t, body, "testdata/metrics.golden")
+	})
+
+	t.Run("state machine", func(t *testing.T) {
+		t.Parallel()
+		collector := promzstate.NewCollector()
+		builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent](zstate.WithMetrics(collector))
+		sm, err := builder.
+			AddState(Closed).
+			AddState(Open).
+			AddState(Locked).
+			AddTransition(Closed, Open, OpenDoor).
+			AddTransition(Open, Closed, CloseDoor).
+			AddTransition(Closed, Locked, LockDoor, zstate.WithGuard(func(ctx context.Context, from, to DoorState, event DoorEvent) bool {
+				return false
+			})).
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+
+		ctx := context.Background()
+		current := Closed
+		for _, event := range []DoorEvent{OpenDoor, CloseDoor, OpenDoor, LockDoor, CloseDoor, LockDoor} {
+			if next, err := sm.Trigger(ctx, current, event); err == nil {
+				current = next
+			}
+		}
+
+		body := scrape(t, collector)
+		for _, want := range []string{
+			`zstate_transitions_total{from="Closed",to="Open",event="OpenDoor"} 2`,
+			`zstate_transitions_total{from="Open",to="Closed",event="CloseDoor"} 2`,
+			`zstate_guard_rejections_total{from="Closed",event="LockDoor"} 1`,
+			`zstate_no_transitions_total{from="Open",event="LockDoor"} 1`,
+			`zstate_callback_duration_seconds_count{kind="guard",event="LockDoor"} 1`,
+		} {
+			if !strings.Contains(body, want+"\n") {
+				t.Errorf("Expected metrics to contain %q, got:\n%s", want, body)
+			}
+		}
+	})
+}
+
+// scrape serves the metrics of collector from a local server and returns the response body
+func scrape(t *testing.T, collector *promzstate.Collector) string {
+	t.Helper()
+
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("Expected the text exposition content type, got %q", got)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return string(body)
+}
+
+func assertGolden(t *testing.T, got, goldenFile string) {
+	t.Helper()
+
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatalf("Failed to create golden file directory: %v", err)
+		}
+		if err := os.WriteFile(goldenFile, []byte(got), 0644); err != nil {
+			t.Fatalf("Failed to update golden file: %v", err)
+		}
+	}
+
+	expected, err := os.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+
+	if got != string(expected) {
+		t.Errorf("Metrics do not match golden file.\nExpected:\n%s\n\nGot:\n%s", expected, got)
+	}
+}
@@ -0,0 +1,55 @@
+package zstate
+
+import (
+	"context"
+)
+
+// defaultMaxCascadeDepth is the maximum cascade depth of raised events unless set with WithMaxCascadeDepth
+const defaultMaxCascadeDepth = 100
+
+// WithMaxCascadeDepth sets how deeply events raised during callbacks may cascade: an event
+// raised while processing a raised event has a depth one greater than the event that raised it.
+// When the limit is exceeded, Fire returns a CascadeError. The default is 100.
+func WithMaxCascadeDepth(depth int) MachineOption {
+	return func(c *machineConfig) {
+		c.maxCascadeDepth = depth
+	}
+}
+
+// queueKey is the context key of the event queue of the Machine processing an event
+type queueKey struct{}
+
+// raisedEvent is an event queued by Raise
+type raisedEvent[E comparable] struct {
+	event   E
+	payload any
+	depth   int
+}
+
+// eventQueue holds the events raised while a Machine processes an event
+type eventQueue[E comparable] struct {
+	events []raisedEvent[E]
+	// depth is the cascade depth of the event being processed
+	depth int
+	// closed is set once the Machine has finished processing the queue
+	closed bool
+}
+
+// Raise queues event on the Machine whose callbacks received ctx.
+// Raised events are processed in order once the current transition has completed,
+// before Fire returns. Raise reports whether the event was queued; it returns false if
+// ctx does not come from a Machine currently processing events of type E.
+// Raise must be called from the goroutine running the callback.
+func Raise[E comparable](ctx context.Context, event E) bool {
+	return RaiseWith(ctx, event, nil)
+}
+
+// RaiseWith works like Raise for an event carrying a payload, as described for TriggerWith
+func RaiseWith[E comparable](ctx context.Context, event E, payload any) bool {
+	q, ok := ctx.Value(queueKey{}).(*eventQueue[E])
+	if !ok || q.closed {
+		return false
+	}
+	q.events = append(q.events, raisedEvent[E]{event: event, payload: payload, depth: q.depth + 1})
+	return true
+}
@@ -0,0 +1,120 @@
+package zstate_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/upamune/zstate"
+)
+
+func TestRaise(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("raised events run after the transition", func(t *testing.T) {
+		t.Parallel()
+		var calls []string
+		record := func(name string) zstate.TransitionCallback[JobState, JobEvent] {
+			return func(ctx context.Context, from, to JobState, event JobEvent) {
+				calls = append(calls, name)
+			}
+		}
+
+		builder := zstate.NewStateMachineBuilder[JobState, JobEvent]()
+		sm, err := builder.
+			AddState(Queued).
+			AddState(Running, zstate.OnEnter(func(ctx context.Context, from, to JobState, event JobEvent) {
+				calls = append(calls, "enter Running")
+				if !zstate.Raise(ctx, Hold) || !zstate.Raise(ctx, Start) {
+					t.Error("Expected events to be raised")
+				}
+			})).
+			AddState(Held).
+			AddTransition(Queued, Running, Start, zstate.WithAfter(record("after Start"))).
+			AddTransition(Running, Held, Hold, zstate.WithAfter(record("after Hold"))).
+			AddTransition(Held, Running, Start, zstate.WithAfter(record("after resume"))).
+			AddTransition(Running, Queued, Requeue).
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+
+		m, err := zstate.NewMachine(sm, Queued, zstate.WithMaxCascadeDepth(2))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		// Every entry into Running raises Hold and Start again, so the cascade only stops at the limit
+		err = m.Fire(ctx, Start)
+		var cascadeErr *zstate.CascadeError[JobEvent]
+		if !errors.As(err, &cascadeErr) || cascadeErr.MaxDepth != 2 {
+			t.Fatalf("Expected CascadeError with max depth 2, got %v", err)
+		}
+		want := []string{
+			"enter Running", "after Start", // depth 0
+			"after Hold", "enter Running", "after resume", // depth 1
+			"after Hold", "enter Running", "after resume", // depth 2
+		}
+		if !slices.Equal(calls, want) {
+			t.Errorf("Expected calls %v, got %v", want, calls)
+		}
+		if m.Current() != Running {
+			t.Errorf("Expected state Running, got %v", m.Current())
+		}
+	})
+
+	t.Run("raised event fails", func(t *testing.T) {
+		t.Parallel()
+		builder := zstate.NewStateMachineBuilder[JobState, JobEvent]()
+		sm, err := builder.
+			AddState(Queued).
+			AddState(Running).
+			AddTransition(Queued, Running, Start, zstate.WithAfter(func(ctx context.Context, from, to JobState, event JobEvent) {
+				zstate.Raise(ctx, Complete)
+			})).
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+
+		m, err := zstate.NewMachine(sm, Queued)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		err = m.Fire(ctx, Start)
+		var noTransitionErr *zstate.NoTransitionError[JobState, JobEvent]
+		if !errors.As(err, &noTransitionErr) || noTransitionErr.Event != Complete {
+			t.Fatalf("Expected NoTransitionError for Complete, got %v", err)
+		}
+		if m.Current() != Running {
+			t.Errorf("Expected state Running, got %v", m.Current())
+		}
+	})
+
+	t.Run("outside of a machine", func(t *testing.T) {
+		t.Parallel()
+		raised := true
+		builder := zstate.NewStateMachineBuilder[JobState, JobEvent]()
+		sm, err := builder.
+			AddState(Queued).
+			AddState(Running).
+			AddTransition(Queued, Running, Start, zstate.WithAfter(func(ctx context.Context, from, to JobState, event JobEvent) {
+				raised = zstate.Raise(ctx, Complete)
+			})).
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+
+		if _, err := sm.Trigger(ctx, Queued, Start); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if raised {
+			t.Error("Expected Raise to report false outside of a machine")
+		}
+	})
+}
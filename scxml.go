@@ -0,0 +1,352 @@
+package zstate
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	scxmlNamespace = "http://www.w3.org/2005/07/scxml"
+	// zstateNamespace holds the SCXML extensions naming the guards and actions of a definition
+	zstateNamespace = "https://github.com/upamune/zstate"
+)
+
+// scxmlDocument, scxmlState, scxmlExecutable and scxmlTransition are the elements read by ParseSCXML
+type (
+	scxmlDocument struct {
+		XMLName xml.Name     `xml:"http://www.w3.org/2005/07/scxml scxml"`
+		Initial string       `xml:"initial,attr"`
+		States  []scxmlState `xml:",any"`
+	}
+
+	scxmlState struct {
+		XMLName     xml.Name
+		ID          string            `xml:"id,attr"`
+		Initial     string            `xml:"initial,attr"`
+		OnEntry     []scxmlExecutable `xml:"onentry"`
+		OnExit      []scxmlExecutable `xml:"onexit"`
+		Transitions []scxmlTransition `xml:"transition"`
+		Children    []scxmlState      `xml:",any"`
+	}
+
+	scxmlExecutable struct {
+		Actions []scxmlAction  `xml:"https://github.com/upamune/zstate action"`
+		Other   []scxmlElement `xml:",any"`
+	}
+
+	scxmlTransition struct {
+		Event   string         `xml:"event,attr"`
+		Target  string         `xml:"target,attr"`
+		Cond    string         `xml:"cond,attr"`
+		Type    string         `xml:"type,attr"`
+		Default bool           `xml:"https://github.com/upamune/zstate default,attr"`
+		Before  []scxmlAction  `xml:"https://github.com/upamune/zstate before"`
+		After   []scxmlAction  `xml:"https://github.com/upamune/zstate after"`
+		Other   []scxmlElement `xml:",any"`
+	}
+
+	scxmlAction struct {
+		Name string `xml:"name,attr"`
+	}
+
+	scxmlElement struct {
+		XMLName xml.Name
+	}
+)
+
+// scxmlDocumentOut, scxmlStateOut, scxmlExecutableOut and scxmlTransitionOut are the elements
+// written by WriteSCXML. They name the extension elements with their prefix, which
+// encoding/xml does not derive from a namespace.
+type (
+	scxmlDocumentOut struct {
+		XMLName     xml.Name        `xml:"scxml"`
+		Xmlns       string          `xml:"xmlns,attr"`
+		XmlnsZstate string          `xml:"xmlns:zstate,attr"`
+		Version     string          `xml:"version,attr"`
+		Initial     string          `xml:"initial,attr,omitempty"`
+		States      []scxmlStateOut `xml:"state"`
+	}
+
+	scxmlStateOut struct {
+		XMLName     xml.Name
+		ID          string               `xml:"id,attr"`
+		OnEntry     *scxmlExecutableOut  `xml:"onentry"`
+		OnExit      *scxmlExecutableOut  `xml:"onexit"`
+		Transitions []scxmlTransitionOut `xml:"transition"`
+		Children    []scxmlStateOut      `xml:"state"`
+	}
+
+	scxmlExecutableOut struct {
+		Actions []scxmlAction `xml:"zstate:action"`
+	}
+
+	scxmlTransitionOut struct {
+		Event   string        `xml:"event,attr"`
+		Target  string        `xml:"target,attr,omitempty"`
+		Cond    string        `xml:"cond,attr,omitempty"`
+		Default bool          `xml:"zstate:default,attr,omitempty"`
+		Before  []scxmlAction `xml:"zstate:before"`
+		After   []scxmlAction `xml:"zstate:after"`
+	}
+)
+
+// ParseSCXML reads a W3C SCXML document and returns the definition of the state machine it describes,
+// ready to be built with a Registry.
+//
+// Nested <state> and <final> elements become states with their parent, and the initial
+// attribute of <scxml> the initial state; without it, the first state of the document is
+// the initial state, as in SCXML. Every <transition> must have a single event;
+// one without a target becomes an internal transition. The cond attribute names the guards
+// of the transition, separated by "&&". Guards and actions are named with elements of the
+// https://github.com/upamune/zstate namespace: <zstate:action name="..."/> in <onentry> and
+// <onexit>, <zstate:before name="..."/> and <zstate:after name="..."/> in <transition>,
+// and the zstate:default attribute marks the default branch. Other elements, such as
+// <parallel>, <history> or executable content, and the initial attribute of <state>
+// are reported as an SCXMLError. So are transitions and initial states targeting a state
+// with children, which SCXML enters at its first child while zstate stays in the state itself.
+func ParseSCXML[S, E ~string](r io.Reader) (*Definition[S, E], error) {
+	var doc scxmlDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, &SCXMLError{Msg: "invalid document", Err: err}
+	}
+
+	d := &Definition[S, E]{}
+	if doc.Initial != "" {
+		initial := S(doc.Initial)
+		d.Initial = &initial
+	}
+	if err := parseSCXMLStates(d, doc.States, nil); err != nil {
+		return nil, err
+	}
+	if d.Initial == nil && len(d.States) > 0 {
+		initial := d.States[0].Name
+		d.Initial = &initial
+	}
+	if err := checkSCXMLTargets(d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// checkSCXMLTargets reports the initial state or a transition of d targeting a state with
+// children as an SCXMLError, because SCXML and zstate enter such a state differently
+func checkSCXMLTargets[S, E ~string](d *Definition[S, E]) error {
+	compound := make(map[S]bool)
+	for _, s := range d.States {
+		if s.Parent != nil {
+			compound[*s.Parent] = true
+		}
+	}
+
+	initial := d.Initial
+	if initial == nil && len(d.States) > 0 {
+		initial = &d.States[0].Name
+	}
+	if initial != nil && compound[*initial] {
+		return &SCXMLError{Msg: fmt.Sprintf("initial state %q has child states; use one of its children instead", *initial)}
+	}
+	for _, t := range d.Transitions {
+		if !t.Internal && compound[t.To] {
+			return &SCXMLError{Msg: fmt.Sprintf("transition of state %q on %q targets %q, which has child states; target one of its children instead", t.From, t.Event, t.To)}
+		}
+	}
+	return nil
+}
+
+// parseSCXMLStates adds the states and their transitions to d, in document order
+func parseSCXMLStates[S, E ~string](d *Definition[S, E], states []scxmlState, parent *S) error {
+	for _, st := range states {
+		if st.XMLName.Space != scxmlNamespace || (st.XMLName.Local != "state" && st.XMLName.Local != "final") {
+			return &SCXMLError{Msg: fmt.Sprintf("element <%s> is not supported", st.XMLName.Local)}
+		}
+		if st.ID == "" {
+			return &SCXMLError{Msg: fmt.Sprintf("<%s> has no id", st.XMLName.Local)}
+		}
+		if st.Initial != "" {
+			return &SCXMLError{Msg: fmt.Sprintf("initial attribute of state %q is not supported", st.ID)}
+		}
+
+		name := S(st.ID)
+		s := StateDefinition[S]{Name: name, Parent: parent, Final: st.XMLName.Local == "final"}
+		for _, ex := range st.OnEntry {
+			actions, err := ex.names(st.ID)
+			if err != nil {
+				return err
+			}
+			s.OnEnter = append(s.OnEnter, actions...)
+		}
+		for _, ex := range st.OnExit {
+			actions, err := ex.names(st.ID)
+			if err != nil {
+				return err
+			}
+			s.OnExit = append(s.OnExit, actions...)
+		}
+		d.States = append(d.States, s)
+
+		for _, t := range st.Transitions {
+			td, err := parseSCXMLTransition[S, E](name, t)
+			if err != nil {
+				return err
+			}
+			d.Transitions = append(d.Transitions, td)
+		}
+
+		if err := parseSCXMLStates(d, st.Children, &name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// names returns the names of the actions of ex, the executable content of the state id
+func (ex *scxmlExecutable) names(id string) ([]string, error) {
+	if len(ex.Other) > 0 {
+		return nil, &SCXMLError{Msg: fmt.Sprintf("executable content <%s> of state %q is not supported", ex.Other[0].XMLName.Local, id)}
+	}
+	names := make([]string, 0, len(ex.Actions))
+	for _, a := range ex.Actions {
+		names = append(names, a.Name)
+	}
+	return names, nil
+}
+
+// parseSCXMLTransition returns the definition of the transition t of the state from
+func parseSCXMLTransition[S, E ~string](from S, t scxmlTransition) (TransitionDefinition[S, E], error) {
+	unsupported := func(msg string) error {
+		return &SCXMLError{Msg: fmt.Sprintf("transition of state %q on %q: %s", from, t.Event, msg)}
+	}
+
+	td := TransitionDefinition[S, E]{From: from, To: S(t.Target), Event: E(t.Event), Default: t.Default}
+	switch {
+	case len(strings.Fields(t.Event)) != 1:
+		return td, unsupported("a single event is required")
+	case len(strings.Fields(t.Target)) > 1:
+		return td, unsupported("multiple targets are not supported")
+	case t.Type != "" && t.Type != "external":
+		return td, unsupported(fmt.Sprintf("type %q is not supported", t.Type))
+	case len(t.Other) > 0:
+		return td, unsupported(fmt.Sprintf("executable content <%s> is not supported", t.Other[0].XMLName.Local))
+	}
+	if t.Target == "" {
+		td.To = from
+		td.Internal = true
+	}
+
+	if t.Cond != "" {
+		for _, guard := range strings.Split(t.Cond, "&&") {
+			guard = strings.TrimSpace(guard)
+			if guard == "" {
+				return td, unsupported(fmt.Sprintf("condition %q does not name guards", t.Cond))
+			}
+			td.Guards = append(td.Guards, guard)
+		}
+	}
+	for _, a := range t.Before {
+		td.Before = append(td.Before, a.Name)
+	}
+	for _, a := range t.After {
+		td.After = append(td.After, a.Name)
+	}
+	return td, nil
+}
+
+// WriteSCXML writes d to w as a W3C SCXML document in the form read by ParseSCXML.
+// Children are nested in their parent state and transitions are written in the state
+// they leave. Transitions from any state cannot be expressed in SCXML and are reported
+// as an SCXMLError, as are transitions and initial states targeting a state with children.
+func WriteSCXML[S, E ~string](w io.Writer, d *Definition[S, E]) error {
+	if err := checkSCXMLTargets(d); err != nil {
+		return err
+	}
+
+	doc := scxmlDocumentOut{
+		Xmlns:       scxmlNamespace,
+		XmlnsZstate: zstateNamespace,
+		Version:     "1.0",
+	}
+	if d.Initial != nil {
+		doc.Initial = string(*d.Initial)
+	}
+
+	declared := make(map[S]struct{}, len(d.States))
+	for _, s := range d.States {
+		declared[s.Name] = struct{}{}
+	}
+
+	transitions := make(map[S][]scxmlTransitionOut)
+	for _, t := range d.Transitions {
+		if t.Any {
+			return &SCXMLError{Msg: fmt.Sprintf("transition from any state on %q cannot be expressed in SCXML", t.Event)}
+		}
+		if _, ok := declared[t.From]; !ok {
+			return &SCXMLError{Msg: fmt.Sprintf("source state %q of the transition on %q is not defined", t.From, t.Event)}
+		}
+		out := scxmlTransitionOut{Event: string(t.Event), Default: t.Default, Cond: strings.Join(t.Guards, " && ")}
+		if !t.Internal {
+			out.Target = string(t.To)
+		}
+		for _, name := range t.Before {
+			out.Before = append(out.Before, scxmlAction{Name: name})
+		}
+		for _, name := range t.After {
+			out.After = append(out.After, scxmlAction{Name: name})
+		}
+		transitions[t.From] = append(transitions[t.From], out)
+	}
+
+	children := make(map[S][]StateDefinition[S])
+	var roots []StateDefinition[S]
+	for _, s := range d.States {
+		if s.Parent != nil {
+			children[*s.Parent] = append(children[*s.Parent], s)
+		} else {
+			roots = append(roots, s)
+		}
+	}
+	var nest func(states []StateDefinition[S]) []scxmlStateOut
+	nest = func(states []StateDefinition[S]) []scxmlStateOut {
+		var out []scxmlStateOut
+		for _, s := range states {
+			st := scxmlStateOut{
+				XMLName:     xml.Name{Local: "state"},
+				ID:          string(s.Name),
+				OnEntry:     scxmlActions(s.OnEnter),
+				OnExit:      scxmlActions(s.OnExit),
+				Transitions: transitions[s.Name],
+				Children:    nest(children[s.Name]),
+			}
+			if s.Final {
+				st.XMLName.Local = "final"
+			}
+			out = append(out, st)
+		}
+		return out
+	}
+	doc.States = nest(roots)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// scxmlActions returns the executable content running the named actions, or nil if there are none
+func scxmlActions(names []string) *scxmlExecutableOut {
+	if len(names) == 0 {
+		return nil
+	}
+	ex := &scxmlExecutableOut{}
+	for _, name := range names {
+		ex.Actions = append(ex.Actions, scxmlAction{Name: name})
+	}
+	return ex
+}
@@ -0,0 +1,173 @@
+package zstate_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/upamune/zstate"
+)
+
+func TestSCXML(t *testing.T) {
+	t.Parallel()
+
+	files, err := filepath.Glob("testdata/scxml/*.scxml")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("Failed to list the SCXML corpus: %v", err)
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			t.Parallel()
+			def := parseSCXMLFile(t, file)
+
+			// Building the definition and exporting it again yields the same definition
+			sm, err := def.Build(registryFor(def))
+			if err != nil {
+				t.Fatalf("Failed to build state machine: %v", err)
+			}
+			exported, err := sm.Definition()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(exported, def) {
+				t.Errorf("Expected exported definition %+v, got %+v", def, exported)
+			}
+
+			// Writing the definition and reading it back yields the same definition
+			var buf bytes.Buffer
+			if err := zstate.WriteSCXML(&buf, exported); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			reparsed, err := zstate.ParseSCXML[string, string](&buf)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(reparsed, def) {
+				t.Errorf("Expected reparsed definition %+v, got %+v", def, reparsed)
+			}
+		})
+	}
+}
+
+func TestParseSCXMLInitial(t *testing.T) {
+	t.Parallel()
+
+	// Without an initial attribute, SCXML starts in the first state of the document
+	def := parseSCXMLFile(t, "testdata/scxml/light.scxml")
+	if def.Initial == nil || *def.Initial != "Red" {
+		t.Errorf("Expected initial state Red, got %v", def.Initial)
+	}
+}
+
+func TestWriteSCXML(t *testing.T) {
+	t.Parallel()
+
+	def := parseSCXMLFile(t, "testdata/scxml/door.scxml")
+	var buf bytes.Buffer
+	if err := zstate.WriteSCXML(&buf, def); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assertGolden(t, buf.String(), "testdata/scxml/door.golden")
+
+	t.Run("transition from any state", func(t *testing.T) {
+		t.Parallel()
+		def := &zstate.Definition[string, string]{
+			States:      []zstate.StateDefinition[string]{{Name: "Running"}, {Name: "Failed"}},
+			Transitions: []zstate.TransitionDefinition[string, string]{{To: "Failed", Event: "Abort", Any: true}},
+		}
+		var scxmlErr *zstate.SCXMLError
+		if err := zstate.WriteSCXML(&bytes.Buffer{}, def); !errors.As(err, &scxmlErr) {
+			t.Errorf("Expected SCXMLError, got %v", err)
+		}
+	})
+
+	t.Run("transition to a state with children", func(t *testing.T) {
+		t.Parallel()
+		active := "Active"
+		def := &zstate.Definition[string, string]{
+			States:      []zstate.StateDefinition[string]{{Name: "Stopped"}, {Name: active}, {Name: "Playing", Parent: &active}},
+			Transitions: []zstate.TransitionDefinition[string, string]{{From: "Stopped", To: active, Event: "Play"}},
+		}
+		var scxmlErr *zstate.SCXMLError
+		if err := zstate.WriteSCXML(&bytes.Buffer{}, def); !errors.As(err, &scxmlErr) {
+			t.Errorf("Expected SCXMLError, got %v", err)
+		}
+	})
+}
+
+func TestParseSCXMLErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range []struct {
+		file string
+		want string
+	}{
+		{"parallel.scxml", "element <parallel> is not supported"},
+		{"history.scxml", "element <history> is not supported"},
+		{"log.scxml", `executable content <log> of state "Closed" is not supported`},
+		{"eventless.scxml", "a single event is required"},
+		{"initial.scxml", `initial attribute of state "Active" is not supported`},
+		{"compound_target.scxml", `transition of state "Stopped" on "Play" targets "Active", which has child states`},
+		{"compound_initial.scxml", `initial state "Active" has child states`},
+		{"malformed.scxml", "invalid document"},
+	} {
+		t.Run(tt.file, func(t *testing.T) {
+			t.Parallel()
+			f, err := os.Open(filepath.Join("testdata/scxml/invalid", tt.file))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			defer f.Close()
+
+			_, err = zstate.ParseSCXML[string, string](f)
+			var scxmlErr *zstate.SCXMLError
+			if !errors.As(err, &scxmlErr) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected SCXMLError containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// parseSCXMLFile parses the SCXML document in file
+func parseSCXMLFile(t *testing.T, file string) *zstate.Definition[string, string] {
+	t.Helper()
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	def, err := zstate.ParseSCXML[string, string](f)
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %v", file, err)
+	}
+	return def
+}
+
+// registryFor returns a registry with a passing guard or a no-op action for every name used in def
+func registryFor(def *zstate.Definition[string, string]) *zstate.Registry[string, string] {
+	r := zstate.NewRegistry[string, string]()
+	guard := func(ctx context.Context, from, to, event string) bool { return true }
+	action := func(ctx context.Context, from, to, event string) {}
+	for _, s := range def.States {
+		for _, name := range append(s.OnEnter, s.OnExit...) {
+			r.RegisterAction(name, action)
+		}
+	}
+	for _, tr := range def.Transitions {
+		for _, name := range tr.Guards {
+			r.RegisterGuard(name, guard)
+		}
+		for _, name := range append(tr.Before, tr.After...) {
+			r.RegisterAction(name, action)
+		}
+	}
+	return r
+}
@@ -0,0 +1,50 @@
+package zstate
+
+import (
+	"time"
+)
+
+// timeout is an event fired by a Machine after it has stayed in a state for a duration
+type timeout[S, E comparable] struct {
+	state S
+	after time.Duration
+	event E
+}
+
+// AddTimeout makes a Machine fire event after it has stayed in state s for the given duration.
+// The timeout is scheduled whenever s is entered and cancelled when s is exited, so
+// self-transitions restart it while internal transitions and transitions between
+// children of s do not. The event needs a transition from s or its children to have an effect.
+func (b *stateMachineBuilder[S, E]) AddTimeout(s S, after time.Duration, event E) StateMachineBuilder[S, E] {
+	b.timeouts = append(b.timeouts, timeout[S, E]{state: s, after: after, event: event})
+	return b
+}
+
+// timeoutsByState groups the declared timeouts by their state
+func (b *stateMachineBuilder[S, E]) timeoutsByState() map[S][]timeout[S, E] {
+	timeouts := make(map[S][]timeout[S, E])
+	for _, t := range b.timeouts {
+		timeouts[t.state] = append(timeouts[t.state], t)
+	}
+	return timeouts
+}
+
+// Clock schedules the timeouts of a Machine.
+// The default clock uses time.AfterFunc; tests can provide a clock that is advanced manually.
+type Clock interface {
+	// AfterFunc calls f in its own goroutine once the duration d has elapsed
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a call scheduled by a Clock
+type Timer interface {
+	// Stop prevents the call from running and reports whether it was stopped before it ran
+	Stop() bool
+}
+
+// realClock is the Clock backed by the time package
+type realClock struct{}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
@@ -0,0 +1,215 @@
+package zstate_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/upamune/zstate"
+)
+
+type CheckoutState string
+
+const (
+	Browsing        CheckoutState = "Browsing"
+	AwaitingPayment CheckoutState = "AwaitingPayment"
+	Expired         CheckoutState = "Expired"
+	Purchased       CheckoutState = "Purchased"
+)
+
+type CheckoutEvent string
+
+const (
+	Checkout CheckoutEvent = "Checkout"
+	Settle   CheckoutEvent = "Settle"
+	Remind   CheckoutEvent = "Remind"
+	Expire   CheckoutEvent = "Expire"
+)
+
+func TestTimeout(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("fires after the duration", func(t *testing.T) {
+		t.Parallel()
+		clock := &fakeClock{}
+		m := newCheckoutMachine(t, clock, Browsing)
+
+		if err := m.Fire(ctx, Checkout); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		clock.Advance(14 * time.Minute)
+		if m.Current() != AwaitingPayment {
+			t.Fatalf("Expected state AwaitingPayment, got %v", m.Current())
+		}
+		clock.Advance(time.Minute)
+		if m.Current() != Expired {
+			t.Errorf("Expected state Expired, got %v", m.Current())
+		}
+	})
+
+	t.Run("cancelled on exit", func(t *testing.T) {
+		t.Parallel()
+		clock := &fakeClock{}
+		m := newCheckoutMachine(t, clock, Browsing)
+
+		for _, event := range []CheckoutEvent{Checkout, Settle} {
+			if err := m.Fire(ctx, event); err != nil {
+				t.Fatalf("Unexpected error for %v: %v", event, err)
+			}
+			clock.Advance(10 * time.Minute)
+		}
+		clock.Advance(time.Hour)
+		if m.Current() != Purchased {
+			t.Errorf("Expected state Purchased, got %v", m.Current())
+		}
+	})
+
+	t.Run("restarted by self-transitions only", func(t *testing.T) {
+		t.Parallel()
+
+		for _, tt := range []struct {
+			event CheckoutEvent
+			want  CheckoutState
+		}{
+			{Remind, Expired},           // internal transition
+			{Checkout, AwaitingPayment}, // self-transition
+		} {
+			clock := &fakeClock{}
+			m := newCheckoutMachine(t, clock, AwaitingPayment)
+
+			clock.Advance(10 * time.Minute)
+			if err := m.Fire(ctx, tt.event); err != nil {
+				t.Fatalf("Unexpected error for %v: %v", tt.event, err)
+			}
+			clock.Advance(5 * time.Minute)
+			if m.Current() != tt.want {
+				t.Errorf("Expected state %v after %v, got %v", tt.want, tt.event, m.Current())
+			}
+		}
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		t.Parallel()
+		clock := &fakeClock{}
+		m := newCheckoutMachine(t, clock, AwaitingPayment)
+
+		if err := m.Restore(Browsing, nil); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		clock.Advance(time.Hour)
+		if m.Current() != Browsing {
+			t.Fatalf("Expected state Browsing, got %v", m.Current())
+		}
+
+		if err := m.Restore(AwaitingPayment, nil); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		clock.Advance(time.Hour)
+		if m.Current() != Expired {
+			t.Errorf("Expected state Expired, got %v", m.Current())
+		}
+	})
+
+	t.Run("invalid timeouts", func(t *testing.T) {
+		t.Parallel()
+		builder := zstate.NewStateMachineBuilder[CheckoutState, CheckoutEvent]()
+		_, err := builder.
+			AddState(Browsing).
+			AddTimeout(AwaitingPayment, time.Minute, Expire).
+			AddTimeout(Browsing, 0, Expire).
+			Build()
+		var stateErr *zstate.StateError[CheckoutState]
+		if !errors.As(err, &stateErr) || stateErr.State != AwaitingPayment {
+			t.Fatalf("Expected StateError for AwaitingPayment, got %v", err)
+		}
+		if got := len(err.(interface{ Unwrap() []error }).Unwrap()); got != 2 {
+			t.Errorf("Expected 2 errors, got %d: %v", got, err)
+		}
+	})
+}
+
+func newCheckoutMachine(t *testing.T, clock zstate.Clock, initial CheckoutState) *zstate.Machine[CheckoutState, CheckoutEvent] {
+	t.Helper()
+
+	builder := zstate.NewStateMachineBuilder[CheckoutState, CheckoutEvent](zstate.WithStrictValidation())
+	sm, err := builder.
+		AddState(Browsing).
+		AddState(AwaitingPayment).
+		AddFinalState(Expired).
+		AddFinalState(Purchased).
+		SetInitial(Browsing).
+		AddTransition(Browsing, AwaitingPayment, Checkout).
+		AddTransition(AwaitingPayment, AwaitingPayment, Checkout).
+		AddInternalTransition(AwaitingPayment, Remind).
+		AddTransition(AwaitingPayment, Purchased, Settle).
+		AddTransition(AwaitingPayment, Expired, Expire).
+		AddTimeout(AwaitingPayment, 15*time.Minute, Expire).
+		Build()
+	if err != nil {
+		t.Fatalf("Failed to build state machine: %v", err)
+	}
+
+	m, err := zstate.NewMachine(sm, initial, zstate.WithClock(clock))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return m
+}
+
+// fakeClock is a Clock that only advances when Advance is called
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Duration
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	at    time.Duration
+	f     func()
+	done  bool
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) zstate.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := &fakeTimer{clock: c, at: c.now + d, f: f}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+// Advance moves the clock forward by d and runs every timer that becomes due, in order
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now + d
+	for {
+		var next *fakeTimer
+		for _, timer := range c.timers {
+			if !timer.done && timer.at <= end && (next == nil || timer.at < next.at) {
+				next = timer
+			}
+		}
+		if next == nil {
+			break
+		}
+		next.done = true
+		c.now = next.at
+		c.mu.Unlock()
+		next.f()
+		c.mu.Lock()
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	stopped := !t.done
+	t.done = true
+	return stopped
+}
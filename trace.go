@@ -0,0 +1,99 @@
+package zstate
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Tracer starts the spans recorded by a state machine configured with WithTracer.
+// The otelzstate package adapts an OpenTelemetry tracer to this interface.
+type Tracer interface {
+	// Start starts a span as a child of the span carried by ctx, if any,
+	// and returns a context carrying the new span
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer
+type Span interface {
+	// SetAttributes adds attributes to the span
+	SetAttributes(attrs ...Attribute)
+	// End ends the span, recording err as its failure if it is not nil
+	End(err error)
+}
+
+// Attribute is a key-value pair describing a span. Value is a string, an int or a bool.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// WithTracer makes the state machine record a span named "zstate.trigger" for every
+// triggered event, as a child of the span carried by the context passed to Trigger.
+// The span has the attributes zstate.from, zstate.to, zstate.event and zstate.outcome,
+// with states and events formatted with fmt.Sprint.
+//
+// Every guard, before callback and after callback runs in a child span named
+// "zstate.guard", "zstate.before" or "zstate.after" with the attribute zstate.index,
+// its position in the order the guards or callbacks were added. Guard spans also carry
+// zstate.allowed and record the error returned by a GuardFunc. The context passed to
+// guards and callbacks carries their span.
+func WithTracer(tracer Tracer) BuilderOption {
+	return func(c *builderConfig) {
+		c.tracer = tracer
+	}
+}
+
+// noopSpan is the span returned when no tracer is set
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attribute) {}
+
+func (noopSpan) End(error) {}
+
+// startTrigger starts the span of an event triggered from the current state
+func (sm *StateMachine[S, E]) startTrigger(ctx context.Context, currentState S, event E) (context.Context, Span) {
+	if sm.tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return sm.tracer.Start(ctx, "zstate.trigger",
+		Attribute{Key: "zstate.from", Value: fmt.Sprint(currentState)},
+		Attribute{Key: "zstate.event", Value: fmt.Sprint(event)},
+	)
+}
+
+// endTrigger ends the span of an event that led to the state to, or failed with err
+func (sm *StateMachine[S, E]) endTrigger(span Span, to S, err error) {
+	if sm.tracer == nil {
+		return
+	}
+	if err == nil {
+		span.SetAttributes(Attribute{Key: "zstate.to", Value: fmt.Sprint(to)})
+	}
+	span.SetAttributes(Attribute{Key: "zstate.outcome", Value: outcomeOf[S, E](err)})
+	span.End(err)
+}
+
+// startCallback starts the span of the guard or callback of the given kind at index.
+// If metrics are set, the span reports the duration of the guard or callback when it ends.
+func (sm *StateMachine[S, E]) startCallback(ctx context.Context, kind string, index int, event E) (context.Context, Span) {
+	var span Span = noopSpan{}
+	if sm.tracer != nil {
+		ctx, span = sm.tracer.Start(ctx, "zstate."+kind, Attribute{Key: "zstate.index", Value: index})
+	}
+	if sm.metrics != nil {
+		span = &timedSpan{Span: span, metrics: sm.metrics, kind: kind, event: fmt.Sprint(event), start: time.Now()}
+	}
+	return ctx, span
+}
+
+// endGuard ends the span of a guard that returned err
+func (sm *StateMachine[S, E]) endGuard(span Span, err error) {
+	if sm.tracer != nil {
+		span.SetAttributes(Attribute{Key: "zstate.allowed", Value: err == nil})
+	}
+	if err == errGuardRejected {
+		err = nil
+	}
+	span.End(err)
+}
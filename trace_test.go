@@ -0,0 +1,141 @@
+package zstate_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/upamune/zstate"
+)
+
+func TestTracer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("spans", func(t *testing.T) {
+		t.Parallel()
+		tracer := &recordingTracer{}
+		var parents []string
+		record := func(ctx context.Context) {
+			if s, ok := ctx.Value(spanKey{}).(*recordedSpan); ok {
+				parents = append(parents, s.name)
+			}
+		}
+
+		builder := zstate.NewStateMachineBuilder[JobState, JobEvent](zstate.WithTracer(tracer))
+		sm, err := builder.
+			AddState(Queued).
+			AddState(Running).
+			AddTransition(Queued, Running, Start,
+				zstate.WithGuard(func(ctx context.Context, from, to JobState, event JobEvent) bool {
+					record(ctx)
+					return true
+				}),
+				zstate.WithBefore(func(ctx context.Context, from, to JobState, event JobEvent) {
+					record(ctx)
+				}),
+				zstate.WithAfter(func(ctx context.Context, from, to JobState, event JobEvent) {
+					record(ctx)
+				}),
+			).
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+
+		ctx := context.WithValue(context.Background(), spanKey{}, &recordedSpan{name: "request"})
+		if _, err := sm.Trigger(ctx, Queued, Start); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		want := []recordedSpan{
+			{name: "zstate.trigger", parent: "request", attrs: map[string]any{"zstate.from": "Queued", "zstate.event": "Start", "zstate.to": "Running", "zstate.outcome": "transitioned"}, ended: true},
+			{name: "zstate.guard", parent: "zstate.trigger", attrs: map[string]any{"zstate.index": 0, "zstate.allowed": true}, ended: true},
+			{name: "zstate.before", parent: "zstate.trigger", attrs: map[string]any{"zstate.index": 0}, ended: true},
+			{name: "zstate.after", parent: "zstate.trigger", attrs: map[string]any{"zstate.index": 0}, ended: true},
+		}
+		if !reflect.DeepEqual(tracer.values(), want) {
+			t.Errorf("Expected spans %v, got %v", want, tracer.values())
+		}
+		if wantParents := []string{"zstate.guard", "zstate.before", "zstate.after"}; !reflect.DeepEqual(parents, wantParents) {
+			t.Errorf("Expected callbacks to run in spans %v, got %v", wantParents, parents)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		t.Parallel()
+		tracer := &recordingTracer{}
+		errDenied := errors.New("denied")
+
+		builder := zstate.NewStateMachineBuilder[JobState, JobEvent](zstate.WithTracer(tracer))
+		sm, err := builder.
+			AddState(Queued).
+			AddState(Running).
+			AddTransition(Queued, Running, Start, zstate.WithGuardE(func(ctx context.Context, from, to JobState, event JobEvent) error {
+				return errDenied
+			})).
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+
+		_, triggerErr := sm.Trigger(context.Background(), Queued, Start)
+		if triggerErr == nil {
+			t.Fatal("Expected guard error, got nil")
+		}
+
+		want := []recordedSpan{
+			{name: "zstate.trigger", attrs: map[string]any{"zstate.from": "Queued", "zstate.event": "Start", "zstate.outcome": "guard_rejected"}, err: triggerErr, ended: true},
+			{name: "zstate.guard", parent: "zstate.trigger", attrs: map[string]any{"zstate.index": 0, "zstate.allowed": false}, err: errDenied, ended: true},
+		}
+		if !reflect.DeepEqual(tracer.values(), want) {
+			t.Errorf("Expected spans %v, got %v", want, tracer.values())
+		}
+	})
+}
+
+// spanKey is the context key of the span recorded by recordingTracer
+type spanKey struct{}
+
+// recordingTracer is a zstate.Tracer recording the spans it starts
+type recordingTracer struct {
+	spans []*recordedSpan
+}
+
+type recordedSpan struct {
+	name   string
+	parent string
+	attrs  map[string]any
+	err    error
+	ended  bool
+}
+
+func (r *recordingTracer) Start(ctx context.Context, name string, attrs ...zstate.Attribute) (context.Context, zstate.Span) {
+	s := &recordedSpan{name: name, attrs: make(map[string]any)}
+	if parent, ok := ctx.Value(spanKey{}).(*recordedSpan); ok {
+		s.parent = parent.name
+	}
+	s.SetAttributes(attrs...)
+	r.spans = append(r.spans, s)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// values returns the recorded spans in the order they were started
+func (r *recordingTracer) values() []recordedSpan {
+	spans := make([]recordedSpan, 0, len(r.spans))
+	for _, s := range r.spans {
+		spans = append(spans, *s)
+	}
+	return spans
+}
+
+func (s *recordedSpan) SetAttributes(attrs ...zstate.Attribute) {
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *recordedSpan) End(err error) {
+	s.err = err
+	s.ended = true
+}
@@ -0,0 +1,266 @@
+package zstate
+
+import (
+	"fmt"
+	"slices"
+)
+
+// validateDeclarations checks the initial and final state declarations.
+// Unlike validate, it always runs because these declarations are explicit.
+func (b *stateMachineBuilder[S, E]) validateDeclarations() []error {
+	var errs []error
+
+	if b.initial != nil {
+		if _, ok := b.states[*b.initial]; !ok {
+			errs = append(errs, &StateError[S]{State: *b.initial, Msg: "initial state is not declared"})
+		}
+	}
+
+	for _, s := range b.stateOrder {
+		parent := b.states[s].parent
+		if parent == nil {
+			continue
+		}
+		if _, ok := b.states[*parent]; !ok {
+			errs = append(errs, &StateError[S]{State: s, Msg: fmt.Sprintf("parent state %v is not declared", *parent)})
+			continue
+		}
+		if b.inCycle(s) {
+			errs = append(errs, &StateError[S]{State: s, Msg: "state hierarchy contains a cycle"})
+		}
+	}
+
+	inRegion := make(map[S]struct{})
+	for _, parent := range b.stateOrder {
+		for _, region := range b.regions[parent] {
+			if len(region) == 0 {
+				errs = append(errs, &StateError[S]{State: parent, Msg: "region has no states"})
+			}
+			for _, s := range region {
+				if _, ok := inRegion[s]; ok {
+					errs = append(errs, &StateError[S]{State: s, Msg: "state belongs to more than one region"})
+				}
+				inRegion[s] = struct{}{}
+			}
+		}
+	}
+
+	for _, t := range b.declared {
+		if t.history != NoHistory && t.internal {
+			errs = append(errs, &TransitionError[S, E]{From: t.from, To: t.to, Event: t.event, Msg: "internal transition must not target history"})
+		} else if t.history != NoHistory && !b.hasChildren(t.to) {
+			errs = append(errs, &TransitionError[S, E]{From: t.from, To: t.to, Event: t.event, Msg: "history group has no child states"})
+		}
+	}
+
+	for _, t := range b.timeouts {
+		if _, ok := b.states[t.state]; !ok {
+			errs = append(errs, &StateError[S]{State: t.state, Msg: "timeout state is not declared"})
+		}
+		if t.after <= 0 {
+			errs = append(errs, &StateError[S]{State: t.state, Msg: fmt.Sprintf("timeout duration %v is not positive", t.after)})
+		}
+	}
+
+	type key struct {
+		from   S
+		event  E
+		source sourceKind
+	}
+	defaults := make(map[key]struct{})
+	for _, t := range b.declared {
+		if _, ok := b.finals[t.from]; ok && t.source != fromAny {
+			errs = append(errs, &TransitionError[S, E]{From: t.from, To: t.to, Event: t.event, Msg: "final state must not have outgoing transitions"})
+		}
+		if t.isDefault {
+			k := key{from: t.from, event: t.event, source: t.source}
+			if _, ok := defaults[k]; ok {
+				errs = append(errs, &TransitionError[S, E]{From: t.from, To: t.to, Event: t.event, Msg: "multiple default transitions for source state and event"})
+			}
+			defaults[k] = struct{}{}
+		}
+	}
+
+	return errs
+}
+
+// validate collects every structural problem of the state machine being built.
+// Problems are reported in declaration order so that the joined error is stable.
+func (b *stateMachineBuilder[S, E]) validate() []error {
+	var errs []error
+
+	type key struct {
+		from   S
+		event  E
+		source sourceKind
+	}
+	// firstUnconditional records, per source state and event, the first regular transition
+	// without guards or typed payload and data options; candidates evaluated after it can never fire
+	firstUnconditional := make(map[key]int, len(b.declared))
+	for i, t := range b.declared {
+		k := key{from: t.from, event: t.event, source: t.source}
+		if _, ok := firstUnconditional[k]; !ok && len(t.guards) == 0 && len(t.accepts) == 0 && !t.isDefault {
+			firstUnconditional[k] = i
+		}
+	}
+
+	for i, t := range b.declared {
+		if _, ok := b.states[t.from]; !ok && t.source != fromAny {
+			errs = append(errs, &TransitionError[S, E]{From: t.from, To: t.to, Event: t.event, Msg: "source state is not declared"})
+		}
+		if _, ok := b.states[t.to]; !ok {
+			errs = append(errs, &TransitionError[S, E]{From: t.from, To: t.to, Event: t.event, Msg: "target state is not declared"})
+		}
+		if first, ok := firstUnconditional[key{from: t.from, event: t.event, source: t.source}]; ok && (first < i || t.isDefault) {
+			errs = append(errs, &TransitionError[S, E]{From: t.from, To: t.to, Event: t.event, Msg: "duplicate transition for source state and event"})
+		}
+	}
+
+	reachable := b.reachable()
+	for _, s := range b.stateOrder {
+		if _, ok := reachable[s]; !ok {
+			errs = append(errs, &StateError[S]{State: s, Msg: "state is unreachable from the initial state"})
+		}
+		if _, final := b.finals[s]; !final && !b.hasOutgoing(s) && !b.leftFromRegions(s) {
+			errs = append(errs, &StateError[S]{State: s, Msg: "state has no outgoing transitions"})
+		}
+	}
+
+	return errs
+}
+
+// reachable returns the set of states reachable from the initial state.
+// The first declared state is used if no initial state was set.
+func (b *stateMachineBuilder[S, E]) reachable() map[S]struct{} {
+	visited := make(map[S]struct{}, len(b.states))
+	if len(b.stateOrder) == 0 {
+		return visited
+	}
+	initial := b.stateOrder[0]
+	if b.initial != nil {
+		initial = *b.initial
+	}
+
+	own := make(map[S][]S, len(b.states))
+	var fromAnyState []S
+	for _, t := range b.declared {
+		if t.internal {
+			continue
+		}
+		if t.source == fromAny {
+			fromAnyState = append(fromAnyState, t.to)
+			continue
+		}
+		own[t.from] = append(own[t.from], t.to)
+	}
+
+	var queue []S
+	// visit marks s as reachable; being in a state means being in all of its ancestors,
+	// and being in a parallel state means being in the initial state of each of its regions
+	var visit func(s S)
+	visit = func(s S) {
+		for _, ancestor := range b.ancestors(s) {
+			if _, ok := visited[ancestor]; ok {
+				continue
+			}
+			visited[ancestor] = struct{}{}
+			queue = append(queue, ancestor)
+			for _, region := range b.regions[ancestor] {
+				if len(region) > 0 {
+					visit(region[0])
+				}
+			}
+		}
+	}
+
+	visit(initial)
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		// Children inherit the transitions of their ancestors
+		for _, ancestor := range b.ancestors(s) {
+			for _, to := range own[ancestor] {
+				visit(to)
+			}
+		}
+		if _, final := b.finals[s]; !final {
+			for _, to := range fromAnyState {
+				visit(to)
+			}
+		}
+	}
+	return visited
+}
+
+// hasOutgoing reports whether s or one of its ancestors has an outgoing transition.
+// Internal transitions do not leave the state and are not counted.
+func (b *stateMachineBuilder[S, E]) hasOutgoing(s S) bool {
+	if len(b.anyTransitions) > 0 {
+		return true
+	}
+	for _, ancestor := range b.ancestors(s) {
+		if len(b.setTransitions[ancestor]) > 0 {
+			return true
+		}
+		for _, candidates := range b.transitions[ancestor] {
+			for _, t := range candidates {
+				if !t.internal {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// leftFromRegions reports whether a transition of a state in a region of the parallel state s
+// targets a state outside of s, which exits s
+func (b *stateMachineBuilder[S, E]) leftFromRegions(s S) bool {
+	if len(b.regions[s]) == 0 {
+		return false
+	}
+	for _, t := range b.declared {
+		if t.internal || t.source == fromAny || t.from == s {
+			continue
+		}
+		if slices.Contains(b.ancestors(t.from), s) && !slices.Contains(b.ancestors(t.to), s) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasChildren reports whether some declared state has s as its parent
+func (b *stateMachineBuilder[S, E]) hasChildren(s S) bool {
+	for _, st := range b.states {
+		if st.parent != nil && *st.parent == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ancestors returns s followed by its declared ancestors, stopping before a cycle repeats
+func (b *stateMachineBuilder[S, E]) ancestors(s S) []S {
+	chain := []S{s}
+	seen := map[S]struct{}{s: {}}
+	for {
+		st := b.states[s]
+		if st == nil || st.parent == nil {
+			return chain
+		}
+		s = *st.parent
+		if _, ok := seen[s]; ok {
+			return chain
+		}
+		seen[s] = struct{}{}
+		chain = append(chain, s)
+	}
+}
+
+// inCycle reports whether following the parents of s leads back to s
+func (b *stateMachineBuilder[S, E]) inCycle(s S) bool {
+	chain := b.ancestors(s)
+	last := b.states[chain[len(chain)-1]]
+	return last != nil && last.parent != nil && *last.parent == s
+}
@@ -0,0 +1,190 @@
+package zstate_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/upamune/zstate"
+)
+
+func TestStrictValidation(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid machine", func(t *testing.T) {
+		t.Parallel()
+		builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent](zstate.WithStrictValidation())
+		_, err := builder.
+			AddState(Closed).
+			AddState(Open).
+			AddState(Locked).
+			AddTransition(Closed, Open, OpenDoor).
+			AddTransition(Open, Closed, CloseDoor).
+			AddTransition(Closed, Locked, LockDoor).
+			AddTransition(Locked, Closed, UnlockDoor).
+			Build()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("lenient by default", func(t *testing.T) {
+		t.Parallel()
+		builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent]()
+		_, err := builder.
+			AddState(Closed).
+			AddTransition(Closed, Open, OpenDoor).
+			Build()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("collects every problem", func(t *testing.T) {
+		t.Parallel()
+		builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent](zstate.WithStrictValidation())
+		_, err := builder.
+			AddState(Closed).
+			AddState(Open).
+			AddState(Locked).
+			AddTransition(Closed, Open, OpenDoor).
+			AddTransition(Closed, Open, OpenDoor).
+			AddTransition(Open, "Broken", CloseDoor).
+			AddTransition("Ajar", Closed, CloseDoor).
+			Build()
+		if err == nil {
+			t.Fatal("Expected error, got nil")
+		}
+
+		var transitionErrs []*zstate.TransitionError[DoorState, DoorEvent]
+		var stateErrs []*zstate.StateError[DoorState]
+		for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+			var transitionErr *zstate.TransitionError[DoorState, DoorEvent]
+			var stateErr *zstate.StateError[DoorState]
+			switch {
+			case errors.As(e, &transitionErr):
+				transitionErrs = append(transitionErrs, transitionErr)
+			case errors.As(e, &stateErr):
+				stateErrs = append(stateErrs, stateErr)
+			default:
+				t.Errorf("Unexpected error type %T: %v", e, e)
+			}
+		}
+
+		wantTransitionMsgs := []string{
+			"duplicate transition for source state and event",
+			"target state is not declared",
+			"source state is not declared",
+		}
+		if len(transitionErrs) != len(wantTransitionMsgs) {
+			t.Fatalf("Expected %d transition errors, got %d: %v", len(wantTransitionMsgs), len(transitionErrs), err)
+		}
+		for i, msg := range wantTransitionMsgs {
+			if transitionErrs[i].Msg != msg {
+				t.Errorf("Expected transition error %q, got %q", msg, transitionErrs[i].Msg)
+			}
+		}
+
+		wantStateErrs := []zstate.StateError[DoorState]{
+			{State: Locked, Msg: "state is unreachable from the initial state"},
+			{State: Locked, Msg: "state has no outgoing transitions"},
+		}
+		if len(stateErrs) != len(wantStateErrs) {
+			t.Fatalf("Expected %d state errors, got %d: %v", len(wantStateErrs), len(stateErrs), err)
+		}
+		for i, want := range wantStateErrs {
+			if *stateErrs[i] != want {
+				t.Errorf("Expected state error %+v, got %+v", want, *stateErrs[i])
+			}
+		}
+	})
+
+	t.Run("conditional branches", func(t *testing.T) {
+		t.Parallel()
+		guard := zstate.WithGuard[DoorState, DoorEvent](func(ctx context.Context, from, to DoorState, event DoorEvent) bool {
+			return true
+		})
+		builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent](zstate.WithStrictValidation())
+		_, err := builder.
+			AddState(Closed).
+			AddState(Open).
+			AddState(Locked).
+			AddTransition(Closed, Open, OpenDoor, guard).
+			AddTransition(Closed, Locked, OpenDoor, zstate.AsDefault[DoorState, DoorEvent]()).
+			AddTransition(Open, Closed, CloseDoor).
+			AddTransition(Locked, Closed, UnlockDoor).
+			Build()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		builder = zstate.NewStateMachineBuilder[DoorState, DoorEvent]()
+		_, err = builder.
+			AddState(Closed).
+			AddState(Open).
+			AddState(Locked).
+			AddTransition(Closed, Open, OpenDoor, zstate.AsDefault[DoorState, DoorEvent]()).
+			AddTransition(Closed, Locked, OpenDoor, zstate.AsDefault[DoorState, DoorEvent]()).
+			Build()
+		var transitionErr *zstate.TransitionError[DoorState, DoorEvent]
+		if !errors.As(err, &transitionErr) || transitionErr.Msg != "multiple default transitions for source state and event" {
+			t.Fatalf("Expected multiple default transitions error, got %v", err)
+		}
+	})
+
+	t.Run("typed payload branches", func(t *testing.T) {
+		t.Parallel()
+		builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent](zstate.WithStrictValidation())
+		sm, err := builder.
+			AddState(Closed).
+			AddState(Open).
+			AddState(Locked).
+			AddTransition(Closed, Open, OpenDoor, zstate.WithPayloadBefore[DoorState, DoorEvent](func(ctx context.Context, from, to DoorState, event DoorEvent, code int) {})).
+			AddTransition(Closed, Locked, OpenDoor, zstate.WithPayloadBefore[DoorState, DoorEvent](func(ctx context.Context, from, to DoorState, event DoorEvent, reason string) {})).
+			AddTransition(Open, Closed, CloseDoor).
+			AddTransition(Locked, Closed, UnlockDoor).
+			Build()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		state, err := sm.TriggerWith(context.Background(), Closed, OpenDoor, "jammed")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if state != Locked {
+			t.Errorf("Expected state Locked, got %v", state)
+		}
+	})
+
+	t.Run("parallel states", func(t *testing.T) {
+		t.Parallel()
+		build := func(exit func(zstate.StateMachineBuilder[DeviceState, DeviceEvent])) error {
+			builder := zstate.NewStateMachineBuilder[DeviceState, DeviceEvent](zstate.WithStrictValidation()).
+				AddState(Device).
+				AddFinalState(Dead).
+				SetInitial(Device).
+				AddRegion(Device, Off, On).
+				AddTransition(Off, On, PowerOn).
+				AddTransition(On, Off, Reset)
+			exit(builder)
+			_, err := builder.Build()
+			return err
+		}
+
+		err := build(func(b zstate.StateMachineBuilder[DeviceState, DeviceEvent]) {})
+		var stateErr *zstate.StateError[DeviceState]
+		if !errors.As(err, &stateErr) || stateErr.State != Device || stateErr.Msg != "state has no outgoing transitions" {
+			t.Errorf("Expected dead-end error for Device, got %v", err)
+		}
+
+		for name, exit := range map[string]func(zstate.StateMachineBuilder[DeviceState, DeviceEvent]){
+			"from the parallel state": func(b zstate.StateMachineBuilder[DeviceState, DeviceEvent]) { b.AddTransition(Device, Dead, Break) },
+			"from a region":           func(b zstate.StateMachineBuilder[DeviceState, DeviceEvent]) { b.AddTransition(On, Dead, Break) },
+		} {
+			if err := build(exit); err != nil {
+				t.Errorf("Unexpected error for an exit %s: %v", name, err)
+			}
+		}
+	})
+}
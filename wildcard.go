@@ -0,0 +1,52 @@
+package zstate
+
+import "slices"
+
+// sourceKind tells how the source of a transition was declared
+type sourceKind int
+
+const (
+	fromState sourceKind = iota
+	fromSet
+	fromAny
+)
+
+// AddTransitionFromStates adds a transition from each of the given states.
+// Transitions added with AddTransition for a state and event take precedence over these.
+func (b *stateMachineBuilder[S, E]) AddTransitionFromStates(from []S, to S, event E, opts ...TransitionOption[S, E]) StateMachineBuilder[S, E] {
+	set := slices.Clone(from)
+	for _, f := range from {
+		t := transition[S, E]{
+			from:   f,
+			to:     to,
+			event:  event,
+			source: fromSet,
+			set:    set,
+		}
+		for _, opt := range opts {
+			opt(&t)
+		}
+
+		addCandidate(b.setTransitions, f, t)
+		b.declared = append(b.declared, t)
+	}
+	return b
+}
+
+// AddTransitionFromAny adds a transition that is valid from every non-final state.
+// It has the lowest precedence: transitions added with AddTransition or
+// AddTransitionFromStates for the current state and event are evaluated first.
+func (b *stateMachineBuilder[S, E]) AddTransitionFromAny(to S, event E, opts ...TransitionOption[S, E]) StateMachineBuilder[S, E] {
+	t := transition[S, E]{
+		to:     to,
+		event:  event,
+		source: fromAny,
+	}
+	for _, opt := range opts {
+		opt(&t)
+	}
+
+	b.anyTransitions[event] = insertCandidate(b.anyTransitions[event], t)
+	b.declared = append(b.declared, t)
+	return b
+}
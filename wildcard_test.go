@@ -0,0 +1,164 @@
+package zstate_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/upamune/zstate"
+)
+
+type JobState string
+
+const (
+	Queued    JobState = "Queued"
+	Running   JobState = "Running"
+	Held      JobState = "Held"
+	Failed    JobState = "Failed"
+	Completed JobState = "Completed"
+)
+
+type JobEvent string
+
+const (
+	Start    JobEvent = "Start"
+	Hold     JobEvent = "Hold"
+	Complete JobEvent = "Complete"
+	Abort    JobEvent = "Abort"
+	Requeue  JobEvent = "Requeue"
+)
+
+func TestWildcardTransitions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("precedence", func(t *testing.T) {
+		t.Parallel()
+		sm := buildJobStateMachine(t)
+
+		for _, tt := range []struct {
+			from JobState
+			want JobState
+		}{
+			{Queued, Failed},  // from any state
+			{Running, Failed}, // from a set of states
+			{Held, Queued},    // specific transition
+		} {
+			got, err := sm.Trigger(ctx, tt.from, Abort)
+			if err != nil {
+				t.Fatalf("Unexpected error from %v: %v", tt.from, err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected state %v from %v, got %v", tt.want, tt.from, got)
+			}
+		}
+	})
+
+	t.Run("from any state", func(t *testing.T) {
+		t.Parallel()
+		sm := buildJobStateMachine(t)
+
+		for _, from := range []JobState{Queued, Running, Held, Failed} {
+			got, err := sm.Trigger(ctx, from, Requeue)
+			if err != nil {
+				t.Fatalf("Unexpected error from %v: %v", from, err)
+			}
+			if got != Queued {
+				t.Errorf("Expected state Queued from %v, got %v", from, got)
+			}
+		}
+	})
+
+	t.Run("not from final states", func(t *testing.T) {
+		t.Parallel()
+		sm := buildJobStateMachine(t)
+
+		_, err := sm.Trigger(ctx, Completed, Requeue)
+		var noTransitionErr *zstate.NoTransitionError[JobState, JobEvent]
+		if !errors.As(err, &noTransitionErr) {
+			t.Fatalf("Expected NoTransitionError, got %v", err)
+		}
+	})
+
+	t.Run("guarded wildcard falls through", func(t *testing.T) {
+		t.Parallel()
+		builder := zstate.NewStateMachineBuilder[JobState, JobEvent]()
+		sm, err := builder.
+			AddState(Queued).
+			AddState(Running).
+			AddState(Failed).
+			AddTransitionFromStates([]JobState{Queued, Running}, Failed, Abort, zstate.WithGuard[JobState, JobEvent](func(ctx context.Context, from, to JobState, event JobEvent) bool {
+				return from == Running
+			})).
+			AddTransitionFromAny(Queued, Abort).
+			Build()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		got, err := sm.Trigger(ctx, Queued, Abort)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got != Queued {
+			t.Errorf("Expected state Queued, got %v", got)
+		}
+	})
+
+	t.Run("final state in source set", func(t *testing.T) {
+		t.Parallel()
+		builder := zstate.NewStateMachineBuilder[JobState, JobEvent]()
+		_, err := builder.
+			AddState(Queued).
+			AddFinalState(Completed).
+			AddTransitionFromStates([]JobState{Queued, Completed}, Queued, Requeue).
+			Build()
+		var transitionErr *zstate.TransitionError[JobState, JobEvent]
+		if !errors.As(err, &transitionErr) || transitionErr.From != Completed {
+			t.Fatalf("Expected TransitionError from Completed, got %v", err)
+		}
+	})
+
+	t.Run("strict validation", func(t *testing.T) {
+		t.Parallel()
+		builder := zstate.NewStateMachineBuilder[JobState, JobEvent](zstate.WithStrictValidation())
+		_, err := builder.
+			AddState(Queued).
+			AddState(Running).
+			AddTransition(Queued, Running, Start).
+			AddTransitionFromAny(Failed, Abort).
+			Build()
+		var transitionErr *zstate.TransitionError[JobState, JobEvent]
+		if !errors.As(err, &transitionErr) || transitionErr.To != Failed {
+			t.Fatalf("Expected TransitionError to Failed, got %v", err)
+		}
+	})
+}
+
+func buildJobStateMachine(t *testing.T) *zstate.StateMachine[JobState, JobEvent] {
+	t.Helper()
+
+	builder := zstate.NewStateMachineBuilder[JobState, JobEvent](zstate.WithStrictValidation())
+	sm, err := builder.
+		AddState(Queued).
+		AddState(Running).
+		AddState(Held).
+		AddState(Failed).
+		AddFinalState(Completed).
+		SetInitial(Queued).
+		AddTransition(Queued, Running, Start).
+		AddTransition(Running, Held, Hold).
+		AddTransition(Held, Running, Start).
+		AddTransition(Running, Completed, Complete).
+		AddTransition(Held, Queued, Abort).
+		AddTransitionFromStates([]JobState{Running, Held}, Failed, Abort).
+		AddTransitionFromAny(Failed, Abort).
+		AddTransitionFromAny(Queued, Requeue).
+		Build()
+
+	if err != nil {
+		t.Fatalf("Failed to build state machine: %v", err)
+	}
+	return sm
+}
@@ -0,0 +1,8 @@
+module github.com/upamune/zstate/yamlzstate
+
+go 1.22
+
+require (
+	github.com/upamune/zstate v0.0.0-20261016092520-4b1de971ebe3
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,44 @@
+// Package yamlzstate reads and writes zstate definitions as YAML.
+//
+// The keys are the same as those of the JSON form of a definition:
+//
+//	def, err := yamlzstate.Decode[DoorState, DoorEvent](f)
+//	if err != nil {
+//		return err
+//	}
+//	sm, err := def.Build(registry)
+package yamlzstate
+
+import (
+	"errors"
+	"io"
+
+	"github.com/upamune/zstate"
+	"gopkg.in/yaml.v3"
+)
+
+// Decode reads a definition from the YAML document in r.
+// Keys that are not part of a definition are reported as an error.
+func Decode[S, E comparable](r io.Reader) (*zstate.Definition[S, E], error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+
+	var def zstate.Definition[S, E]
+	if err := dec.Decode(&def); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("yamlzstate: empty document")
+		}
+		return nil, err
+	}
+	return &def, nil
+}
+
+// Encode writes def to w as a YAML document indented by two spaces
+func Encode[S, E comparable](w io.Writer, def *zstate.Definition[S, E]) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(def); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,123 @@
+package yamlzstate_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/upamune/zstate"
+	"github.com/upamune/zstate/yamlzstate"
+)
+
+type DoorState string
+
+const (
+	Closed DoorState = "Closed"
+	Open   DoorState = "Open"
+	Locked DoorState = "Locked"
+)
+
+type DoorEvent string
+
+const (
+	OpenDoor   DoorEvent = "OpenDoor"
+	CloseDoor  DoorEvent = "CloseDoor"
+	LockDoor   DoorEvent = "LockDoor"
+	UnlockDoor DoorEvent = "UnlockDoor"
+)
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("round trip", func(t *testing.T) {
+		t.Parallel()
+		data, err := os.ReadFile("testdata/definition_door.yaml")
+		if err != nil {
+			t.Fatalf("Failed to read definition: %v", err)
+		}
+		def, err := yamlzstate.Decode[DoorState, DoorEvent](bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		var calls []string
+		registry := zstate.NewRegistry[DoorState, DoorEvent]().
+			RegisterGuard("hasKey", func(ctx context.Context, from, to DoorState, event DoorEvent) bool {
+				return true
+			}).
+			RegisterAction("record", func(ctx context.Context, from, to DoorState, event DoorEvent) {
+				calls = append(calls, string(from)+" -> "+string(to))
+			})
+		sm, err := def.Build(registry)
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+		if _, err := sm.Trigger(ctx, Closed, OpenDoor); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if want := []string{"Closed -> Open", "Closed -> Open"}; !reflect.DeepEqual(calls, want) {
+			t.Errorf("Expected calls %v, got %v", want, calls)
+		}
+
+		exported, err := sm.Definition()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var got bytes.Buffer
+		if err := yamlzstate.Encode(&got, exported); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got.String() != string(data) {
+			t.Errorf("Exported definition does not match.\nExpected:\n%s\nGot:\n%s", data, got.String())
+		}
+	})
+
+	t.Run("same keys as JSON", func(t *testing.T) {
+		t.Parallel()
+		f, err := os.Open("testdata/definition_door.yaml")
+		if err != nil {
+			t.Fatalf("Failed to read definition: %v", err)
+		}
+		defer f.Close()
+		def, err := yamlzstate.Decode[DoorState, DoorEvent](f)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		data, err := os.ReadFile("../testdata/definition_door.json")
+		if err != nil {
+			t.Fatalf("Failed to read definition: %v", err)
+		}
+		var want zstate.Definition[DoorState, DoorEvent]
+		if err := json.Unmarshal(data, &want); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(*def, want) {
+			t.Errorf("Expected the JSON definition %+v, got %+v", want, *def)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		t.Parallel()
+		for _, tt := range []struct {
+			name string
+			yaml string
+			want string
+		}{
+			{"unknown key", "states:\n  - name: Closed\n    onenter: [record]\n", "field onenter not found"},
+			{"empty document", "", "empty document"},
+			{"invalid document", "states: [", "yaml:"},
+		} {
+			_, err := yamlzstate.Decode[DoorState, DoorEvent](strings.NewReader(tt.yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+			}
+		}
+	})
+}
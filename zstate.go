@@ -3,81 +3,299 @@ package zstate
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"slices"
+	"time"
 )
 
 // StateMachine represents the state machine entity with generic state type S and event type E
 type StateMachine[S, E comparable] struct {
-	states      map[S]struct{}
-	transitions map[S]map[E]transition[S, E]
+	states      map[S]*state[S, E]
+	transitions map[S]map[E][]transition[S, E]
+	initial     *S
+	finals      map[S]struct{}
+	regions     map[S][][]S
+	// setTransitions and anyTransitions hold the transitions added with
+	// AddTransitionFromStates and AddTransitionFromAny
+	setTransitions map[S]map[E][]transition[S, E]
+	anyTransitions map[E][]transition[S, E]
+	// historyGroups holds the states targeted by history transitions
+	historyGroups map[S]struct{}
+	timeouts      map[S][]timeout[S, E]
+	listeners     listeners[S, E]
+	// logger is set with WithLogger, or nil
+	logger    *slog.Logger
+	logLevels LogLevels
+	// tracer and metrics are set with WithTracer and WithMetrics, or nil
+	tracer  Tracer
+	metrics Metrics
+	// stateOrder and declared keep the declaration order for Definition
+	stateOrder []S
+	declared   []transition[S, E]
+}
+
+// state represents a state in the state machine
+type state[S, E comparable] struct {
+	parent  *S
+	onEnter []TransitionCallback[S, E]
+	onExit  []TransitionCallback[S, E]
+	// onEnterNames and onExitNames hold the names of the actions added by Definition.Build
+	onEnterNames []string
+	onExitNames  []string
 }
 
 // transition represents a transition in the state machine
 type transition[S, E comparable] struct {
-	from   S
-	to     S
-	event  E
-	guard  Guard[S, E]
-	before TransitionCallback[S, E]
-	after  TransitionCallback[S, E]
+	from    S
+	to      S
+	event   E
+	guards  []guardFn[S, E]
+	befores []beforeFn[S, E]
+	afters  []afterFn[S, E]
+	// accepts check the payload and data of an event against the types expected by typed options
+	accepts []func(d *dispatch) error
+	// dataTypes are the types of extended state expected by the data options of the transition
+	dataTypes []reflect.Type
+	// isDefault marks the else branch taken when every other candidate is rejected
+	isDefault bool
+	// history makes the transition resume the history of the target state
+	history HistoryKind
+	// source tells whether the transition was declared for a single state, a set of states or any state
+	source sourceKind
+	// set holds every source state of a transition added with AddTransitionFromStates
+	set []S
+	// internal transitions run their callbacks without exiting or entering any state
+	internal bool
+	// guardNames, beforeNames and afterNames hold the names of the guards and callbacks added by Definition.Build
+	guardNames  []string
+	beforeNames []string
+	afterNames  []string
 }
 
 // Guard is a function type that determines if a transition is allowed
 type Guard[S, E comparable] func(ctx context.Context, from, to S, event E) bool
 
+// GuardFunc is a guard that reports why a transition is not allowed.
+// A nil error allows the transition; any other error rejects it and is wrapped in a GuardError.
+type GuardFunc[S, E comparable] func(ctx context.Context, from, to S, event E) error
+
 // TransitionCallback is a function type for before and after transition callbacks
 type TransitionCallback[S, E comparable] func(ctx context.Context, from, to S, event E)
 
+// TransitionCallbackE is a transition callback that can fail.
+// A non-nil error returned from a before callback aborts the transition and is wrapped in a TransitionError.
+type TransitionCallbackE[S, E comparable] func(ctx context.Context, from, to S, event E) error
+
+// guardFn, beforeFn and afterFn are the forms in which guards and callbacks are stored.
+// They receive the values accompanying the event being processed.
+type (
+	guardFn[S, E comparable]  func(ctx context.Context, from, to S, event E, d *dispatch) error
+	beforeFn[S, E comparable] func(ctx context.Context, from, to S, event E, d *dispatch) error
+	afterFn[S, E comparable]  func(ctx context.Context, from, to S, event E, d *dispatch)
+)
+
+// dispatch holds the values accompanying a single event
+type dispatch struct {
+	// payload is the value passed to TriggerWith, or nil
+	payload any
+	// data is the extended state of an ExtendedStateMachine, or nil; data actions replace it
+	data any
+}
+
+// errGuardRejected is returned by guards added with WithGuard when they return false
+var errGuardRejected = errors.New("condition not met")
+
 // StateMachineBuilder is the interface for building a state machine
 type StateMachineBuilder[S, E comparable] interface {
-	AddState(s S) StateMachineBuilder[S, E]
+	AddState(s S, opts ...StateOption[S, E]) StateMachineBuilder[S, E]
+	AddFinalState(s S, opts ...StateOption[S, E]) StateMachineBuilder[S, E]
+	AddRegion(parent S, states ...S) StateMachineBuilder[S, E]
+	AddTransitionFromStates(from []S, to S, event E, opts ...TransitionOption[S, E]) StateMachineBuilder[S, E]
+	AddTransitionFromAny(to S, event E, opts ...TransitionOption[S, E]) StateMachineBuilder[S, E]
+	SetInitial(s S) StateMachineBuilder[S, E]
 	AddTransition(from, to S, event E, opts ...TransitionOption[S, E]) StateMachineBuilder[S, E]
+	AddInternalTransition(s S, event E, opts ...TransitionOption[S, E]) StateMachineBuilder[S, E]
+	AddTimeout(s S, after time.Duration, event E) StateMachineBuilder[S, E]
+	OnTransition(listener TransitionCallback[S, E]) StateMachineBuilder[S, E]
+	OnGuardRejected(listener GuardRejectedListener[S, E]) StateMachineBuilder[S, E]
+	OnNoTransition(listener NoTransitionListener[S, E]) StateMachineBuilder[S, E]
 	Build() (*StateMachine[S, E], error)
 }
 
 type stateMachineBuilder[S, E comparable] struct {
-	states      map[S]struct{}
-	transitions map[S]map[E]transition[S, E]
+	states         map[S]*state[S, E]
+	stateOrder     []S
+	transitions    map[S]map[E][]transition[S, E]
+	declared       []transition[S, E]
+	initial        *S
+	finals         map[S]struct{}
+	regions        map[S][][]S
+	setTransitions map[S]map[E][]transition[S, E]
+	anyTransitions map[E][]transition[S, E]
+	timeouts       []timeout[S, E]
+	listeners      listeners[S, E]
+	config         builderConfig
+}
+
+// BuilderOption is a function type for configuring a StateMachineBuilder
+type BuilderOption func(*builderConfig)
+
+type builderConfig struct {
+	strict    bool
+	logger    *slog.Logger
+	logLevels LogLevels
+	tracer    Tracer
+	metrics   Metrics
+}
+
+// WithStrictValidation makes Build reject state machines with structural problems.
+// Build then reports transitions between undeclared states, transitions shadowed by
+// an earlier unguarded transition for the same source state and event, states unreachable from the initial state
+// and non-final states without outgoing transitions, all joined into a single error.
+// If no initial state is set, the first declared state is used as the initial state.
+func WithStrictValidation() BuilderOption {
+	return func(c *builderConfig) {
+		c.strict = true
+	}
 }
 
 // TransitionOption is a function type for configuring transitions
 type TransitionOption[S, E comparable] func(*transition[S, E])
 
-// WithGuard adds a guard function to a transition
+// WithGuard adds a guard function to a transition.
+// A transition may have several guards; all of them must pass, and they are evaluated in the order they were added.
 func WithGuard[S, E comparable](guard Guard[S, E]) TransitionOption[S, E] {
 	return func(t *transition[S, E]) {
-		t.guard = guard
+		t.guards = append(t.guards, func(ctx context.Context, from, to S, event E, _ *dispatch) error {
+			if !guard(ctx, from, to, event) {
+				return errGuardRejected
+			}
+			return nil
+		})
 	}
 }
 
-// WithBefore adds a before callback to a transition
+// WithGuardE adds an error-returning guard function to a transition
+func WithGuardE[S, E comparable](guard GuardFunc[S, E]) TransitionOption[S, E] {
+	return func(t *transition[S, E]) {
+		t.guards = append(t.guards, func(ctx context.Context, from, to S, event E, _ *dispatch) error {
+			return guard(ctx, from, to, event)
+		})
+	}
+}
+
+// AsDefault marks a transition as the default branch for its source state and event.
+// The default branch is evaluated after every other candidate transition, regardless of declaration order.
+func AsDefault[S, E comparable]() TransitionOption[S, E] {
+	return func(t *transition[S, E]) {
+		t.isDefault = true
+	}
+}
+
+// WithBefore adds a before callback to a transition.
+// Before callbacks, including those added with WithBeforeE, run in the order they were added,
+// ahead of the exit actions of the current state.
 func WithBefore[S, E comparable](callback TransitionCallback[S, E]) TransitionOption[S, E] {
 	return func(t *transition[S, E]) {
-		t.before = callback
+		t.befores = append(t.befores, func(ctx context.Context, from, to S, event E, _ *dispatch) error {
+			callback(ctx, from, to, event)
+			return nil
+		})
+	}
+}
+
+// WithBeforeE adds an error-returning before callback to a transition.
+// It runs in the order it was added together with the callbacks added with WithBefore.
+// If the callback returns an error, the transition is aborted before any exit action
+// has run and the remaining callbacks are skipped.
+func WithBeforeE[S, E comparable](callback TransitionCallbackE[S, E]) TransitionOption[S, E] {
+	return func(t *transition[S, E]) {
+		t.befores = append(t.befores, func(ctx context.Context, from, to S, event E, _ *dispatch) error {
+			return callback(ctx, from, to, event)
+		})
 	}
 }
 
-// WithAfter adds an after callback to a transition
+// WithAfter adds an after callback to a transition.
+// After callbacks run in the order they were added.
 func WithAfter[S, E comparable](callback TransitionCallback[S, E]) TransitionOption[S, E] {
 	return func(t *transition[S, E]) {
-		t.after = callback
+		t.afters = append(t.afters, func(ctx context.Context, from, to S, event E, _ *dispatch) {
+			callback(ctx, from, to, event)
+		})
+	}
+}
+
+// StateOption is a function type for configuring states
+type StateOption[S, E comparable] func(*state[S, E])
+
+// OnEnter adds an entry action to a state.
+// Entry actions run whenever a transition enters the state, including self-transitions.
+func OnEnter[S, E comparable](callback TransitionCallback[S, E]) StateOption[S, E] {
+	return func(s *state[S, E]) {
+		s.onEnter = append(s.onEnter, callback)
+	}
+}
+
+// OnExit adds an exit action to a state.
+// Exit actions run whenever a transition leaves the state, including self-transitions.
+func OnExit[S, E comparable](callback TransitionCallback[S, E]) StateOption[S, E] {
+	return func(s *state[S, E]) {
+		s.onExit = append(s.onExit, callback)
 	}
 }
 
 // NewStateMachineBuilder creates a new StateMachineBuilder
-func NewStateMachineBuilder[S, E comparable]() StateMachineBuilder[S, E] {
-	return &stateMachineBuilder[S, E]{
-		states:      make(map[S]struct{}),
-		transitions: make(map[S]map[E]transition[S, E]),
+func NewStateMachineBuilder[S, E comparable](opts ...BuilderOption) StateMachineBuilder[S, E] {
+	b := &stateMachineBuilder[S, E]{
+		states:         make(map[S]*state[S, E]),
+		transitions:    make(map[S]map[E][]transition[S, E]),
+		finals:         make(map[S]struct{}),
+		regions:        make(map[S][][]S),
+		setTransitions: make(map[S]map[E][]transition[S, E]),
+		anyTransitions: make(map[E][]transition[S, E]),
+	}
+	for _, opt := range opts {
+		opt(&b.config)
+	}
+	return b
+}
+
+// AddState adds a new state to the state machine.
+// Adding a state again applies the given options to the existing state.
+func (b *stateMachineBuilder[S, E]) AddState(s S, opts ...StateOption[S, E]) StateMachineBuilder[S, E] {
+	st, ok := b.states[s]
+	if !ok {
+		st = &state[S, E]{}
+		b.states[s] = st
+		b.stateOrder = append(b.stateOrder, s)
 	}
+
+	for _, opt := range opts {
+		opt(st)
+	}
+	return b
 }
 
-// AddState adds a new state to the state machine
-func (b *stateMachineBuilder[S, E]) AddState(s S) StateMachineBuilder[S, E] {
-	b.states[s] = struct{}{}
+// AddFinalState adds a new final state to the state machine.
+// Final states are terminal and must not have outgoing transitions.
+func (b *stateMachineBuilder[S, E]) AddFinalState(s S, opts ...StateOption[S, E]) StateMachineBuilder[S, E] {
+	b.AddState(s, opts...)
+	b.finals[s] = struct{}{}
 	return b
 }
 
-// AddTransition adds a new transition to the state machine
+// SetInitial sets the initial state of the state machine
+func (b *stateMachineBuilder[S, E]) SetInitial(s S) StateMachineBuilder[S, E] {
+	b.initial = &s
+	return b
+}
+
+// AddTransition adds a new transition to the state machine.
+// Several transitions may share the same source state and event; Trigger evaluates
+// them in declaration order and takes the first one whose guards all pass.
 func (b *stateMachineBuilder[S, E]) AddTransition(from, to S, event E, opts ...TransitionOption[S, E]) StateMachineBuilder[S, E] {
 	t := transition[S, E]{
 		from:  from,
@@ -89,43 +307,299 @@ func (b *stateMachineBuilder[S, E]) AddTransition(from, to S, event E, opts ...T
 		opt(&t)
 	}
 
-	if b.transitions[from] == nil {
-		b.transitions[from] = make(map[E]transition[S, E])
+	addCandidate(b.transitions, from, t)
+	b.declared = append(b.declared, t)
+	return b
+}
+
+// AddInternalTransition adds a new internal transition to the state machine.
+// An internal transition handles event in s by running its callbacks, but unlike a
+// self-transition it neither exits nor re-enters s, so no entry or exit actions run.
+// Children of s inherit it and stay in their current state.
+func (b *stateMachineBuilder[S, E]) AddInternalTransition(s S, event E, opts ...TransitionOption[S, E]) StateMachineBuilder[S, E] {
+	t := transition[S, E]{
+		from:     s,
+		to:       s,
+		event:    event,
+		internal: true,
+	}
+
+	for _, opt := range opts {
+		opt(&t)
 	}
-	b.transitions[from][event] = t
+
+	addCandidate(b.transitions, s, t)
+	b.declared = append(b.declared, t)
 	return b
 }
 
+// addCandidate adds t to the candidate transitions of from for its event
+func addCandidate[S, E comparable](transitions map[S]map[E][]transition[S, E], from S, t transition[S, E]) {
+	if transitions[from] == nil {
+		transitions[from] = make(map[E][]transition[S, E])
+	}
+	transitions[from][t.event] = insertCandidate(transitions[from][t.event], t)
+}
+
+// insertCandidate inserts t into candidates, keeping default branches behind every regular candidate
+func insertCandidate[S, E comparable](candidates []transition[S, E], t transition[S, E]) []transition[S, E] {
+	i := len(candidates)
+	if !t.isDefault {
+		for i > 0 && candidates[i-1].isDefault {
+			i--
+		}
+	}
+	return slices.Insert(candidates, i, t)
+}
+
 // Build finalizes the construction of the state machine
 func (b *stateMachineBuilder[S, E]) Build() (*StateMachine[S, E], error) {
 	if len(b.states) == 0 {
 		return nil, &StateError[S]{Msg: "state machine must have at least one state"}
 	}
 
+	errs := b.validateDeclarations()
+	if b.config.strict {
+		errs = append(errs, b.validate()...)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
 	return &StateMachine[S, E]{
-		states:      b.states,
-		transitions: b.transitions,
+		states:         b.states,
+		transitions:    b.transitions,
+		initial:        b.initial,
+		finals:         b.finals,
+		regions:        b.regions,
+		historyGroups:  b.historyGroups(),
+		setTransitions: b.setTransitions,
+		anyTransitions: b.anyTransitions,
+		timeouts:       b.timeoutsByState(),
+		listeners:      b.listeners,
+		logger:         b.config.logger,
+		logLevels:      b.config.logLevels,
+		tracer:         b.config.tracer,
+		metrics:        b.config.metrics,
+		stateOrder:     b.stateOrder,
+		declared:       b.declared,
 	}, nil
 }
 
-// Trigger attempts to perform a transition based on the given event
+// Initial returns the initial state of the state machine and whether one was set
+func (sm *StateMachine[S, E]) Initial() (S, bool) {
+	if sm.initial == nil {
+		var zero S
+		return zero, false
+	}
+	return *sm.initial, true
+}
+
+// IsFinal reports whether s is a final state of the state machine
+func (sm *StateMachine[S, E]) IsFinal(s S) bool {
+	_, ok := sm.finals[s]
+	return ok
+}
+
+// Trigger attempts to perform a transition based on the given event.
+// Actions run in the following order: before callbacks of the transition, exit actions
+// of the current state, entry actions of the new state and after callbacks of the transition.
+// Self-transitions exit and re-enter the state, while internal transitions only run
+// their before and after callbacks.
+// With hierarchical states, exit actions run from the current state outwards and
+// entry actions from the outermost entered state inwards.
+// If a before callback fails, the state is left unchanged and no exit action has run.
+//
+// The listeners registered with OnTransition, OnGuardRejected and OnNoTransition
+// are notified of the outcome of every call, which is also logged if the builder
+// was created with WithLogger.
 func (sm *StateMachine[S, E]) Trigger(ctx context.Context, currentState S, event E) (S, error) {
-	t, ok := sm.transitions[currentState][event]
-	if !ok {
-		return currentState, &NoTransitionError[S, E]{From: currentState, Event: event}
+	return sm.TriggerWith(ctx, currentState, event, nil)
+}
+
+// trigger selects and executes the transition for event, notifying the listeners of the outcome,
+// logging it and tracing it.
+// It returns the new state and the transition taken. If history is not nil, history
+// transitions are resolved against it and it is updated in place.
+func (sm *StateMachine[S, E]) trigger(ctx context.Context, currentState S, event E, d *dispatch, history History[S]) (S, *transition[S, E], error) {
+	start := time.Now()
+	ctx, span := sm.startTrigger(ctx, currentState, event)
+	t, to, err := sm.resolve(ctx, currentState, event, d, nil, history)
+	if err != nil {
+		sm.endTrigger(span, currentState, err)
+		sm.notifyRejected(ctx, err)
+		sm.logAttempt(ctx, currentState, event, start, currentState, err)
+		return currentState, nil, err
+	}
+
+	exit, enter := sm.steps(currentState, t, to)
+	next, err := sm.execute(ctx, currentState, t, to, exit, enter, event, d, history)
+	sm.endTrigger(span, next, err)
+	sm.logAttempt(ctx, currentState, event, start, next, err)
+	if err != nil {
+		return currentState, nil, err
+	}
+	sm.notifyTransition(ctx, currentState, next, event)
+	return next, t, nil
+}
+
+// execute runs the actions of transition t taken from the current state to the target state to,
+// exiting and entering the given states, and returns the new state. If history is not nil,
+// the innermost state exited is recorded in it for every history group that is exited.
+func (sm *StateMachine[S, E]) execute(ctx context.Context, currentState S, t *transition[S, E], to S, exit, enter []S, event E, d *dispatch, history History[S]) (S, error) {
+	if err := sm.prepare(ctx, currentState, t, to, event, d); err != nil {
+		return currentState, err
+	}
+	return sm.commit(ctx, currentState, t, to, exit, enter, event, d, history), nil
+}
+
+// prepare runs the before callbacks of transition t, which can still abort it
+func (sm *StateMachine[S, E]) prepare(ctx context.Context, currentState S, t *transition[S, E], to S, event E, d *dispatch) error {
+	for i, before := range t.befores {
+		spanCtx, span := sm.startCallback(ctx, "before", i, event)
+		err := before(spanCtx, currentState, to, event, d)
+		span.End(err)
+		if err != nil {
+			return &TransitionError[S, E]{From: currentState, To: to, Event: event, Msg: "before callback failed", Err: err}
+		}
+	}
+	return nil
+}
+
+// commit runs the exit, entry and after actions of transition t once it has been prepared,
+// and returns the new state
+func (sm *StateMachine[S, E]) commit(ctx context.Context, currentState S, t *transition[S, E], to S, exit, enter []S, event E, d *dispatch, history History[S]) S {
+	for _, s := range exit {
+		if st := sm.states[s]; st != nil {
+			for _, action := range st.onExit {
+				action(ctx, currentState, to, event)
+			}
+		}
+	}
+
+	if history != nil {
+		for _, s := range exit {
+			if _, ok := sm.historyGroups[s]; ok {
+				history[s] = sm.innermost(s, exit)
+			}
+		}
+	}
+
+	for _, s := range enter {
+		if st := sm.states[s]; st != nil {
+			for _, action := range st.onEnter {
+				action(ctx, currentState, to, event)
+			}
+		}
+	}
+
+	for i, after := range t.afters {
+		spanCtx, span := sm.startCallback(ctx, "after", i, event)
+		after(spanCtx, currentState, to, event, d)
+		span.End(nil)
 	}
 
-	if t.guard != nil && !t.guard(ctx, currentState, t.to, event) {
-		return currentState, &GuardError[S, E]{From: currentState, To: t.to, Event: event}
+	return to
+}
+
+// steps returns the states exited and entered when t is taken from the current state to the target state to.
+// Entering a parallel state enters the initial state of each of its regions.
+func (sm *StateMachine[S, E]) steps(currentState S, t *transition[S, E], to S) (exit, enter []S) {
+	if t.internal {
+		return nil, nil
 	}
+	exit, enter = sm.path(currentState, t.from, to)
+	return exit, sm.complete(enter)
+}
 
-	if t.before != nil {
-		t.before(ctx, currentState, t.to, event)
+// innermost returns the first of the exited states inside group, which was the innermost active state of group
+func (sm *StateMachine[S, E]) innermost(group S, exit []S) S {
+	for _, s := range exit {
+		if slices.Contains(sm.ancestors(s), group) {
+			return s
+		}
 	}
+	return group
+}
 
-	if t.after != nil {
-		defer t.after(ctx, currentState, t.to, event)
+// resolve selects the transition taken for event from the current state and its target state.
+// Candidates of the current state are evaluated first, followed by those of its ancestors.
+// Transitions added for a set of states come next and transitions from any state come last.
+// If boundary is not nil, ancestors from boundary outwards are not considered, and neither are
+// transitions from any state leading out of the region of boundary the current state is in.
+// History transitions are resolved against history.
+func (sm *StateMachine[S, E]) resolve(ctx context.Context, currentState S, event E, d *dispatch, boundary *S, history History[S]) (*transition[S, E], S, error) {
+	var sources []S
+	for _, s := range sm.ancestors(currentState) {
+		if boundary != nil && s == *boundary {
+			break
+		}
+		sources = append(sources, s)
 	}
 
-	return t.to, nil
+	var rejected []*GuardError[S, E]
+	tiers := []map[S]map[E][]transition[S, E]{sm.transitions, sm.setTransitions}
+	for _, transitions := range tiers {
+		for _, s := range sources {
+			candidates := transitions[s][event]
+			for i := range candidates {
+				to := sm.target(&candidates[i], history)
+				if candidates[i].internal {
+					// Internal transitions inherited from an ancestor stay in the current state
+					to = currentState
+				}
+				if err := sm.check(ctx, &candidates[i], currentState, to, event, d); err != nil {
+					rejected = append(rejected, err)
+					continue
+				}
+				return &candidates[i], to, nil
+			}
+		}
+	}
+
+	// Final states are terminal, so transitions from any state do not apply to them
+	if !sm.IsFinal(currentState) {
+		candidates := sm.anyTransitions[event]
+		for i := range candidates {
+			t := candidates[i]
+			t.from = currentState
+			to := sm.target(&t, history)
+			if boundary != nil && sm.regionOf(*boundary, to) != sm.regionOf(*boundary, currentState) {
+				continue
+			}
+			if err := sm.check(ctx, &t, currentState, to, event, d); err != nil {
+				rejected = append(rejected, err)
+				continue
+			}
+			return &t, to, nil
+		}
+	}
+
+	var zero S
+	if len(rejected) > 0 {
+		return nil, zero, newGuardError(rejected)
+	}
+	return nil, zero, &NoTransitionError[S, E]{From: currentState, Event: event}
+}
+
+// check evaluates the guards of transition t in order and reports the first one that fails.
+// A payload or data of the wrong type rejects the transition before any guard runs.
+func (sm *StateMachine[S, E]) check(ctx context.Context, t *transition[S, E], from, to S, event E, d *dispatch) *GuardError[S, E] {
+	for _, accept := range t.accepts {
+		if err := accept(d); err != nil {
+			return &GuardError[S, E]{From: from, To: to, Event: event, Index: -1, Err: err}
+		}
+	}
+	for i, guard := range t.guards {
+		spanCtx, span := sm.startCallback(ctx, "guard", i, event)
+		err := guard(spanCtx, from, to, event, d)
+		sm.endGuard(span, err)
+		if err != nil {
+			if err == errGuardRejected {
+				err = nil
+			}
+			return &GuardError[S, E]{From: from, To: to, Event: event, Index: i, Err: err}
+		}
+	}
+	return nil
 }
@@ -3,6 +3,7 @@ package zstate_test
 import (
 	"context"
 	"errors"
+	"slices"
 	"testing"
 
 	"github.com/upamune/zstate"
@@ -14,6 +15,7 @@ const (
 	Closed DoorState = "Closed"
 	Open   DoorState = "Open"
 	Locked DoorState = "Locked"
+	Broken DoorState = "Broken"
 )
 
 type DoorEvent string
@@ -23,6 +25,7 @@ const (
 	CloseDoor  DoorEvent = "CloseDoor"
 	LockDoor   DoorEvent = "LockDoor"
 	UnlockDoor DoorEvent = "UnlockDoor"
+	BreakDoor  DoorEvent = "BreakDoor"
 )
 
 func TestStateMachine(t *testing.T) {
@@ -126,6 +129,413 @@ func TestStateMachine(t *testing.T) {
 		if !errors.As(err, &guardErr) {
 			t.Fatalf("Expected GuardError, got %T: %v", err, err)
 		}
+		if guardErr.Err != nil {
+			t.Errorf("Expected no cause for a rejected guard, got %v", guardErr.Err)
+		}
+	})
+
+	t.Run("WithGuardE", func(t *testing.T) {
+		t.Parallel()
+		errLookup := errors.New("lookup failed")
+		builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent]()
+		sm, err := builder.
+			AddState(Closed).
+			AddState(Locked).
+			AddTransition(Closed, Locked, LockDoor, zstate.WithGuardE[DoorState, DoorEvent](func(ctx context.Context, from, to DoorState, event DoorEvent) error {
+				return errLookup
+			})).
+			Build()
+
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+
+		newState, err := sm.Trigger(context.Background(), Closed, LockDoor)
+		var guardErr *zstate.GuardError[DoorState, DoorEvent]
+		if !errors.As(err, &guardErr) {
+			t.Fatalf("Expected GuardError, got %T: %v", err, err)
+		}
+		if !errors.Is(err, errLookup) {
+			t.Errorf("Expected error to wrap %v, got %v", errLookup, err)
+		}
+		if newState != Closed {
+			t.Errorf("Expected state Closed, got %v", newState)
+		}
+	})
+
+	t.Run("WithBeforeE aborts the transition", func(t *testing.T) {
+		t.Parallel()
+		errArm := errors.New("alarm unavailable")
+		var afterCalled bool
+		builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent]()
+		sm, err := builder.
+			AddState(Closed).
+			AddState(Locked).
+			AddTransition(Closed, Locked, LockDoor,
+				zstate.WithBeforeE[DoorState, DoorEvent](func(ctx context.Context, from, to DoorState, event DoorEvent) error {
+					return errArm
+				}),
+				zstate.WithAfter[DoorState, DoorEvent](func(ctx context.Context, from, to DoorState, event DoorEvent) {
+					afterCalled = true
+				}),
+			).
+			Build()
+
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+
+		newState, err := sm.Trigger(context.Background(), Closed, LockDoor)
+		var transitionErr *zstate.TransitionError[DoorState, DoorEvent]
+		if !errors.As(err, &transitionErr) {
+			t.Fatalf("Expected TransitionError, got %T: %v", err, err)
+		}
+		if !errors.Is(err, errArm) {
+			t.Errorf("Expected error to wrap %v, got %v", errArm, err)
+		}
+		if newState != Closed {
+			t.Errorf("Expected state Closed, got %v", newState)
+		}
+		if afterCalled {
+			t.Error("After callback was called for an aborted transition")
+		}
+	})
+
+	t.Run("Multiple guards and callbacks", func(t *testing.T) {
+		t.Parallel()
+		var calls []string
+		guard := func(name string, pass bool) zstate.Guard[DoorState, DoorEvent] {
+			return func(ctx context.Context, from, to DoorState, event DoorEvent) bool {
+				calls = append(calls, name)
+				return pass
+			}
+		}
+		record := func(name string) zstate.TransitionCallback[DoorState, DoorEvent] {
+			return func(ctx context.Context, from, to DoorState, event DoorEvent) {
+				calls = append(calls, name)
+			}
+		}
+
+		builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent]()
+		sm, err := builder.
+			AddState(Closed).
+			AddState(Open).
+			AddState(Locked).
+			AddTransition(Closed, Open, OpenDoor,
+				zstate.WithGuard(guard("guard 1", true)),
+				zstate.WithGuard(guard("guard 2", true)),
+				zstate.WithBefore(record("before 1")),
+				zstate.WithBefore(record("before 2")),
+				zstate.WithAfter(record("after 1")),
+				zstate.WithAfter(record("after 2")),
+			).
+			AddTransition(Closed, Locked, LockDoor,
+				zstate.WithGuard(guard("guard A", true)),
+				zstate.WithGuard(guard("guard B", false)),
+				zstate.WithGuard(guard("guard C", true)),
+			).
+			Build()
+
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+
+		if _, err := sm.Trigger(context.Background(), Closed, OpenDoor); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		want := []string{"guard 1", "guard 2", "before 1", "before 2", "after 1", "after 2"}
+		if !slices.Equal(calls, want) {
+			t.Errorf("Expected calls %v, got %v", want, calls)
+		}
+
+		calls = nil
+		_, err = sm.Trigger(context.Background(), Closed, LockDoor)
+		var guardErr *zstate.GuardError[DoorState, DoorEvent]
+		if !errors.As(err, &guardErr) {
+			t.Fatalf("Expected GuardError, got %T: %v", err, err)
+		}
+		if guardErr.Index != 1 {
+			t.Errorf("Expected failing guard index 1, got %d", guardErr.Index)
+		}
+		want = []string{"guard A", "guard B"}
+		if !slices.Equal(calls, want) {
+			t.Errorf("Expected calls %v, got %v", want, calls)
+		}
+	})
+
+	t.Run("Conditional branching", func(t *testing.T) {
+		t.Parallel()
+		type (
+			ExpenseState string
+			ExpenseEvent string
+		)
+		const (
+			Draft    ExpenseState = "Draft"
+			Approved ExpenseState = "Approved"
+			Review   ExpenseState = "Review"
+			Rejected ExpenseState = "Rejected"
+			Submit   ExpenseEvent = "Submit"
+		)
+		type amountKey struct{}
+		below := func(limit int) zstate.Guard[ExpenseState, ExpenseEvent] {
+			return func(ctx context.Context, from, to ExpenseState, event ExpenseEvent) bool {
+				return ctx.Value(amountKey{}).(int) < limit
+			}
+		}
+		errLimit := errors.New("over limit")
+
+		builder := zstate.NewStateMachineBuilder[ExpenseState, ExpenseEvent]()
+		sm, err := builder.
+			AddState(Draft).
+			AddState(Approved).
+			AddState(Review).
+			AddTransition(Draft, Review, Submit, zstate.AsDefault[ExpenseState, ExpenseEvent]()).
+			AddTransition(Draft, Approved, Submit, zstate.WithGuard(below(1000))).
+			AddTransition(Draft, Review, Submit, zstate.WithGuard(below(5000))).
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+
+		for _, tt := range []struct {
+			amount int
+			want   ExpenseState
+		}{
+			{500, Approved},
+			{2000, Review},
+			{9000, Review},
+		} {
+			ctx := context.WithValue(context.Background(), amountKey{}, tt.amount)
+			got, err := sm.Trigger(ctx, Draft, Submit)
+			if err != nil {
+				t.Fatalf("Unexpected error for amount %d: %v", tt.amount, err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected state %v for amount %d, got %v", tt.want, tt.amount, got)
+			}
+		}
+
+		builder = zstate.NewStateMachineBuilder[ExpenseState, ExpenseEvent]()
+		sm, err = builder.
+			AddState(Draft).
+			AddState(Approved).
+			AddState(Rejected).
+			AddTransition(Draft, Approved, Submit, zstate.WithGuard(below(1000))).
+			AddTransition(Draft, Rejected, Submit, zstate.WithGuardE[ExpenseState, ExpenseEvent](func(ctx context.Context, from, to ExpenseState, event ExpenseEvent) error {
+				return errLimit
+			})).
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+
+		ctx := context.WithValue(context.Background(), amountKey{}, 2000)
+		_, err = sm.Trigger(ctx, Draft, Submit)
+		var guardErr *zstate.GuardError[ExpenseState, ExpenseEvent]
+		if !errors.As(err, &guardErr) {
+			t.Fatalf("Expected GuardError, got %T: %v", err, err)
+		}
+		if len(guardErr.Candidates) != 2 {
+			t.Fatalf("Expected 2 rejected candidates, got %d", len(guardErr.Candidates))
+		}
+		if guardErr.Candidates[0].To != Approved || guardErr.Candidates[1].To != Rejected {
+			t.Errorf("Unexpected rejected candidates: %v", err)
+		}
+		if !errors.Is(guardErr.Candidates[1], errLimit) {
+			t.Errorf("Expected second candidate to wrap %v, got %v", errLimit, guardErr.Candidates[1])
+		}
+		if !errors.Is(err, errLimit) {
+			t.Errorf("Expected error to wrap %v of the second candidate, got %v", errLimit, err)
+		}
+	})
+
+	t.Run("Entry and exit actions", func(t *testing.T) {
+		t.Parallel()
+		var calls []string
+		record := func(name string) zstate.TransitionCallback[DoorState, DoorEvent] {
+			return func(ctx context.Context, from, to DoorState, event DoorEvent) {
+				calls = append(calls, name)
+			}
+		}
+
+		builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent]()
+		sm, err := builder.
+			AddState(Closed,
+				zstate.OnEnter(record("enter Closed")),
+				zstate.OnExit(record("exit Closed")),
+			).
+			AddState(Locked,
+				zstate.OnEnter(record("enter Locked")),
+				zstate.OnEnter(record("arm alarm")),
+				zstate.OnExit(record("exit Locked")),
+			).
+			AddTransition(Closed, Locked, LockDoor,
+				zstate.WithBefore(record("before LockDoor")),
+				zstate.WithAfter(record("after LockDoor")),
+			).
+			AddTransition(Locked, Locked, LockDoor,
+				zstate.WithBefore(record("before relock")),
+				zstate.WithAfter(record("after relock")),
+			).
+			Build()
+
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+
+		state, err := sm.Trigger(context.Background(), Closed, LockDoor)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := sm.Trigger(context.Background(), state, LockDoor); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		want := []string{
+			"before LockDoor", "exit Closed", "enter Locked", "arm alarm", "after LockDoor",
+			"before relock", "exit Locked", "enter Locked", "arm alarm", "after relock",
+		}
+		if !slices.Equal(calls, want) {
+			t.Errorf("Expected calls %v, got %v", want, calls)
+		}
+	})
+
+	t.Run("Before callbacks run in registration order ahead of exit actions", func(t *testing.T) {
+		t.Parallel()
+		var calls []string
+		record := func(name string) zstate.TransitionCallback[DoorState, DoorEvent] {
+			return func(ctx context.Context, from, to DoorState, event DoorEvent) {
+				calls = append(calls, name)
+			}
+		}
+		armed := true
+		checkAlarm := func(ctx context.Context, from, to DoorState, event DoorEvent) error {
+			calls = append(calls, "check alarm")
+			if !armed {
+				return errors.New("alarm unavailable")
+			}
+			return nil
+		}
+
+		builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent]()
+		sm, err := builder.
+			AddState(Closed, zstate.OnExit(record("disarm alarm"))).
+			AddState(Open, zstate.OnEnter(record("enter Open"))).
+			AddTransition(Closed, Open, OpenDoor,
+				zstate.WithBefore(record("before OpenDoor")),
+				zstate.WithBeforeE(checkAlarm),
+			).
+			Build()
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+
+		if _, err := sm.Trigger(context.Background(), Closed, OpenDoor); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		want := []string{"before OpenDoor", "check alarm", "disarm alarm", "enter Open"}
+		if !slices.Equal(calls, want) {
+			t.Errorf("Expected calls %v, got %v", want, calls)
+		}
+
+		calls, armed = nil, false
+		if state, err := sm.Trigger(context.Background(), Closed, OpenDoor); err == nil || state != Closed {
+			t.Fatalf("Expected the transition to be aborted in Closed, got %v, %v", state, err)
+		}
+		if want := []string{"before OpenDoor", "check alarm"}; !slices.Equal(calls, want) {
+			t.Errorf("Expected calls %v for an aborted transition, got %v", want, calls)
+		}
+	})
+
+	t.Run("Internal transitions", func(t *testing.T) {
+		t.Parallel()
+		var calls []string
+		record := func(name string) zstate.TransitionCallback[DoorState, DoorEvent] {
+			return func(ctx context.Context, from, to DoorState, event DoorEvent) {
+				calls = append(calls, name)
+			}
+		}
+
+		builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent]()
+		sm, err := builder.
+			AddState(Closed,
+				zstate.OnEnter(record("enter Closed")),
+				zstate.OnExit(record("exit Closed")),
+			).
+			AddState(Locked,
+				zstate.WithParent[DoorState, DoorEvent](Closed),
+				zstate.OnEnter(record("enter Locked")),
+				zstate.OnExit(record("exit Locked")),
+			).
+			AddInternalTransition(Locked, LockDoor,
+				zstate.WithBefore(record("before relock")),
+				zstate.WithAfter(record("after relock")),
+			).
+			AddInternalTransition(Closed, CloseDoor, zstate.WithAfter(record("after CloseDoor"))).
+			Build()
+
+		if err != nil {
+			t.Fatalf("Failed to build state machine: %v", err)
+		}
+
+		for _, event := range []DoorEvent{LockDoor, CloseDoor} {
+			state, err := sm.Trigger(context.Background(), Locked, event)
+			if err != nil {
+				t.Fatalf("Unexpected error for %v: %v", event, err)
+			}
+			if state != Locked {
+				t.Errorf("Expected state Locked for %v, got %v", event, state)
+			}
+		}
+
+		want := []string{"before relock", "after relock", "after CloseDoor"}
+		if !slices.Equal(calls, want) {
+			t.Errorf("Expected calls %v, got %v", want, calls)
+		}
+	})
+
+	t.Run("Initial and final states", func(t *testing.T) {
+		t.Parallel()
+		sm := buildDoorLifecycleStateMachine(t)
+
+		initial, ok := sm.Initial()
+		if !ok || initial != Closed {
+			t.Errorf("Expected initial state Closed, got %v (set: %v)", initial, ok)
+		}
+		if !sm.IsFinal(Broken) {
+			t.Error("Expected Broken to be final")
+		}
+		if sm.IsFinal(Closed) {
+			t.Error("Expected Closed not to be final")
+		}
+	})
+
+	t.Run("undeclared initial state", func(t *testing.T) {
+		t.Parallel()
+		builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent]()
+		_, err := builder.
+			AddState(Closed).
+			SetInitial(Open).
+			Build()
+		var stateErr *zstate.StateError[DoorState]
+		if !errors.As(err, &stateErr) || stateErr.State != Open {
+			t.Fatalf("Expected StateError for Open, got %v", err)
+		}
+	})
+
+	t.Run("final state with outgoing transition", func(t *testing.T) {
+		t.Parallel()
+		builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent]()
+		_, err := builder.
+			AddState(Closed).
+			AddFinalState(Broken).
+			AddTransition(Closed, Broken, BreakDoor).
+			AddTransition(Broken, Closed, CloseDoor).
+			Build()
+		var transitionErr *zstate.TransitionError[DoorState, DoorEvent]
+		if !errors.As(err, &transitionErr) || transitionErr.From != Broken {
+			t.Fatalf("Expected TransitionError from Broken, got %v", err)
+		}
 	})
 }
 
@@ -150,3 +560,42 @@ func buildDoorStateMachine(t *testing.T) *zstate.StateMachine[DoorState, DoorEve
 	}
 	return sm
 }
+
+func buildDoorLifecycleStateMachine(t *testing.T) *zstate.StateMachine[DoorState, DoorEvent] {
+	t.Helper()
+
+	builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent](zstate.WithStrictValidation())
+	sm, err := builder.
+		AddState(Closed).
+		AddState(Open).
+		AddFinalState(Broken).
+		SetInitial(Closed).
+		AddTransition(Closed, Open, OpenDoor).
+		AddTransition(Open, Closed, CloseDoor).
+		AddTransition(Open, Broken, BreakDoor).
+		Build()
+
+	if err != nil {
+		t.Fatalf("Failed to build state machine: %v", err)
+	}
+	return sm
+}
+
+func buildDoorInternalStateMachine(t *testing.T) *zstate.StateMachine[DoorState, DoorEvent] {
+	t.Helper()
+
+	builder := zstate.NewStateMachineBuilder[DoorState, DoorEvent]()
+	sm, err := builder.
+		AddState(Closed).
+		AddState(Open).
+		AddTransition(Closed, Open, OpenDoor).
+		AddTransition(Open, Closed, CloseDoor).
+		AddTransition(Closed, Closed, CloseDoor).
+		AddInternalTransition(Open, OpenDoor).
+		Build()
+
+	if err != nil {
+		t.Fatalf("Failed to build state machine: %v", err)
+	}
+	return sm
+}